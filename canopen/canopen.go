@@ -0,0 +1,108 @@
+// Package canopen implements access to CANopen object dictionary
+// entries using the Modbus CANopen General Reference function
+// (MEI type 13), following the mapping defined by CiA 309-2.
+package canopen
+
+import (
+	"github.com/knieriem/modbus"
+	"github.com/knieriem/modbus/mei"
+)
+
+type Error string
+
+func (e Error) Error() string {
+	return "canopen: " + string(e)
+}
+
+// MEIType is the MEI type of the CANopen General Reference function.
+const MEIType = 13
+
+const (
+	ctlRead  = 0
+	ctlWrite = 1
+
+	// protocol control, reserved, node ID, index (2),
+	// sub-index, starting address (2), number of data (2)
+	hdrLen = 10
+)
+
+// A Client reads and writes object dictionary entries
+// of a CANopen node behind a Modbus gateway.
+type Client struct {
+	tp *mei.Transport
+
+	// NodeID is the ID of the addressed CANopen node.
+	NodeID uint8
+}
+
+func NewClient(dev modbus.Device, nodeID uint8) *Client {
+	c := new(Client)
+	c.tp = mei.NewTransport(dev, MEIType)
+	c.NodeID = nodeID
+	return c
+}
+
+func (c *Client) header(ctl byte, index uint16, subindex uint8, n int) []byte {
+	b := make([]byte, hdrLen, hdrLen+n)
+	b[0] = ctl
+	b[2] = c.NodeID
+	modbus.ByteOrder.PutUint16(b[3:], index)
+	b[5] = subindex
+	modbus.ByteOrder.PutUint16(b[8:], uint16(n))
+	return b
+}
+
+// Read reads the value of the object dictionary entry
+// specified by index and subindex. The length of the result
+// is determined by the response of the gateway.
+func (c *Client) Read(index uint16, subindex uint8, opts ...modbus.ReqOption) ([]byte, error) {
+	// The low byte of the number of data field, the last byte
+	// of the header, determines the length of the response.
+	opts = append(opts, modbus.VariableRespLen(&modbus.VariableRespLenSpec{
+		NumItemsFixed: 1,
+		ItemLenIndex:  1 + 1 + hdrLen - 1,
+	}))
+	resp, err := c.tp.Request(c.header(ctlRead, index, subindex, 0), opts...)
+	if err != nil {
+		return nil, err
+	}
+	data, err := c.checkResp(resp, index, subindex)
+	if err != nil {
+		return nil, err
+	}
+	if n := int(modbus.ByteOrder.Uint16(resp[8:])); n != len(data) {
+		return nil, modbus.NewLengthFieldMismatch(n, len(data))
+	}
+	return append([]byte(nil), data...), nil
+}
+
+// Write writes data to the object dictionary entry
+// specified by index and subindex.
+func (c *Client) Write(index uint16, subindex uint8, data []byte, opts ...modbus.ReqOption) error {
+	if len(data) > modbus.MaxPDULen-2-hdrLen {
+		return modbus.ErrMaxReqLenExceeded
+	}
+	req := append(c.header(ctlWrite, index, subindex, len(data)), data...)
+	opts = append(opts, modbus.ExpectedRespLen(2+hdrLen))
+	resp, err := c.tp.Request(req, opts...)
+	if err != nil {
+		return err
+	}
+	_, err = c.checkResp(resp, index, subindex)
+	return err
+}
+
+// checkResp verifies that the header of the response matches
+// the request, and returns the data following the header.
+func (c *Client) checkResp(resp []byte, index uint16, subindex uint8) ([]byte, error) {
+	if len(resp) < hdrLen {
+		return nil, modbus.NewInvalidLen(modbus.MsgContextData, len(resp), hdrLen)
+	}
+	if resp[2] != c.NodeID {
+		return nil, Error("node ID mismatch")
+	}
+	if modbus.ByteOrder.Uint16(resp[3:]) != index || resp[5] != subindex {
+		return nil, Error("object index mismatch")
+	}
+	return resp[hdrLen:], nil
+}
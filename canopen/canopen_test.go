@@ -0,0 +1,89 @@
+package canopen_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/knieriem/modbus"
+	"github.com/knieriem/modbus/canopen"
+	"github.com/knieriem/modbus/internal/mocknet"
+)
+
+// sdoReadResp is the response of a gateway to a read of the
+// vendor ID (object 0x1018, sub-index 1) of CANopen node 5.
+var sdoReadResp = []byte{
+	0x2B, 0x0D, // function code, MEI type
+	0x00, 0x00, 0x05, // protocol control, reserved, node ID
+	0x10, 0x18, 0x01, // index, sub-index
+	0x00, 0x00, 0x00, 0x04, // starting address, number of data
+	0x19, 0x03, 0x00, 0x00, // vendor ID
+}
+
+func TestRead(t *testing.T) {
+	nc := mocknet.New(func(_ context.Context, req []byte, _ *modbus.ExpectedRespLenSpec) ([]byte, error) {
+		return append([]byte{req[0]}, sdoReadResp...), nil
+	})
+	c := canopen.NewClient(mocknet.Device(modbus.NewNetwork(nc), 1), 5)
+	data, err := c.Read(0x1018, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte{0x19, 0x03, 0x00, 0x00}; !bytes.Equal(data, want) {
+		t.Errorf("got % x, want % x", data, want)
+	}
+	wantReq := []byte{1, 0x2B, 0x0D, 0, 0, 5, 0x10, 0x18, 1, 0, 0, 0, 0}
+	if !bytes.Equal(nc.Sent[0], wantReq) {
+		t.Errorf("sent % x, want % x", nc.Sent[0], wantReq)
+	}
+
+	// the length spec allows the transport to complete
+	// the reception as soon as the complete response is present
+	ls := nc.Specs[0]
+	for n := 0; n < len(sdoReadResp); n++ {
+		if ls.CheckLen(sdoReadResp[:n]) == nil {
+			t.Errorf("response prefix of length %d accepted", n)
+		}
+	}
+	if err := ls.CheckLen(sdoReadResp); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestReadMismatch(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		modify func(resp []byte)
+	}{
+		{"node ID", func(resp []byte) { resp[5]++ }},
+		{"index", func(resp []byte) { resp[7]++ }},
+		{"sub-index", func(resp []byte) { resp[8]++ }},
+	} {
+		nc := mocknet.New(func(_ context.Context, req []byte, _ *modbus.ExpectedRespLenSpec) ([]byte, error) {
+			resp := append([]byte{req[0]}, sdoReadResp...)
+			tc.modify(resp)
+			return resp, nil
+		})
+		c := canopen.NewClient(mocknet.Device(modbus.NewNetwork(nc), 1), 5)
+		_, err := c.Read(0x1018, 1)
+		if _, ok := err.(canopen.Error); !ok {
+			t.Errorf("%s: got %v, want a canopen.Error", tc.name, err)
+		}
+	}
+}
+
+func TestWrite(t *testing.T) {
+	nc := mocknet.New(func(_ context.Context, req []byte, _ *modbus.ExpectedRespLenSpec) ([]byte, error) {
+		// the gateway returns the header of the request
+		return req[:1+2+10], nil
+	})
+	c := canopen.NewClient(mocknet.Device(modbus.NewNetwork(nc), 1), 5)
+	err := c.Write(0x6040, 0, []byte{0x0F, 0x00})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantReq := []byte{1, 0x2B, 0x0D, 1, 0, 5, 0x60, 0x40, 0, 0, 0, 0, 2, 0x0F, 0x00}
+	if !bytes.Equal(nc.Sent[0], wantReq) {
+		t.Errorf("sent % x, want % x", nc.Sent[0], wantReq)
+	}
+}
@@ -33,3 +33,18 @@ func FormatMsg(msgDir string, adu modbus.ADU, err error, ncName string) string {
 	}
 	return s
 }
+
+// FormatEvent formats a TraceEvent like FormatMsg, and,
+// in case the request has been repeated, appends
+// the attempt number and the reason for the retry.
+// A decoded representation of the response is appended too, if present.
+func FormatEvent(ev *modbus.TraceEvent) string {
+	s := FormatMsg(ev.MsgDir, ev.ADU, ev.Err, ev.NetConnName)
+	if ev.Attempt > 1 {
+		s += fmt.Sprintf(" (attempt %d, retry after %s)", ev.Attempt, ev.RetryReason)
+	}
+	if ev.Decoded != "" {
+		s += " = " + ev.Decoded
+	}
+	return s
+}
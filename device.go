@@ -27,18 +27,66 @@ func (d *addressedDevice) Request(fn uint8, req Request, resp Response, opts ...
 
 type DeviceTestFunc func(addr byte, d Device) error
 
+// ScanDevices calls test for each address in the range from addrMin
+// to addrMax. Addresses for which test returns a timeout or an invalid
+// reply are skipped. The scan stops at any other error, including
+// exceptions, which is returned.
 func ScanDevices(bus Bus, addrMin, addrMax byte, test DeviceTestFunc) (err error) {
+	scan(bus, addrMin, addrMax, test, func(_ byte, testErr error) bool {
+		if testErr == nil || noResponse(testErr) {
+			return true
+		}
+		err = testErr
+		return false
+	})
+	return
+}
+
+// A ScanResult describes the outcome of testing a device address.
+type ScanResult struct {
+	Addr      byte
+	Responded bool
+	Err       error
+}
+
+// ScanDevicesReport works like ScanDevices, but instead of silently
+// skipping addresses that did not respond, it records the result
+// for each address tested. Addresses for which test returned nil,
+// or an Exception, are considered to have responded; unlike ScanDevices,
+// it does not stop at exceptions. It stops at errors other than
+// timeouts, invalid replies and exceptions, returning the
+// results recorded so far, including the one of the failing address.
+func ScanDevicesReport(bus Bus, addrMin, addrMax byte, test DeviceTestFunc) (list []ScanResult, err error) {
+	scan(bus, addrMin, addrMax, test, func(addr byte, testErr error) bool {
+		_, isException := testErr.(Exception)
+		list = append(list, ScanResult{
+			Addr:      addr,
+			Responded: testErr == nil || isException,
+			Err:       testErr,
+		})
+		if testErr != nil && !isException && !noResponse(testErr) {
+			err = testErr
+			return false
+		}
+		return true
+	})
+	return list, err
+}
+
+// scan calls test for each address in the range, passing
+// the result to f, until f returns false.
+func scan(bus Bus, addrMin, addrMax byte, test DeviceTestFunc, f func(addr byte, err error) bool) {
 	d := newAddressedDevice(bus)
-	for a := addrMin; a <= addrMax; a++ {
+	for a := int(addrMin); a <= int(addrMax); a++ {
 		d.addr = byte(a)
-		err = test(d.addr, d)
-		if err != nil {
-			if err == ErrTimeout || MsgInvalid(err) {
-				err = nil
-				continue
-			}
-			break
+		if !f(d.addr, test(d.addr, d)) {
+			return
 		}
 	}
-	return
+}
+
+// noResponse reports whether err suggests that no
+// device is present at the address tested.
+func noResponse(err error) bool {
+	return err == ErrTimeout || MsgInvalid(err)
 }
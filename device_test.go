@@ -0,0 +1,97 @@
+package modbus_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/knieriem/modbus"
+	"github.com/knieriem/modbus/internal/mocknet"
+)
+
+var errLinkDown = errors.New("link down")
+
+// scanBus returns a Network with devices behaving
+// differently depending on their address.
+func scanBus() *modbus.Network {
+	return modbus.NewNetwork(mocknet.New(func(_ context.Context, req []byte, _ *modbus.ExpectedRespLenSpec) ([]byte, error) {
+		switch req[0] {
+		case 1, 5, 255:
+			return req[:2], nil
+		case 3:
+			return []byte{req[0], req[1] | 0x80, byte(modbus.XIllegalFunc)}, nil
+		case 4:
+			return nil, modbus.ErrCRC
+		case 6:
+			return nil, errLinkDown
+		}
+		return nil, nil
+	}))
+}
+
+func testDevice(_ byte, d modbus.Device) error {
+	return d.Request(0x41, nil, nil)
+}
+
+func TestScanDevicesReport(t *testing.T) {
+	list, err := modbus.ScanDevicesReport(scanBus(), 1, 7, testDevice)
+	if err != errLinkDown {
+		t.Errorf("got %v, want %v", err, errLinkDown)
+	}
+	want := []modbus.ScanResult{
+		{Addr: 1, Responded: true},
+		{Addr: 2, Err: modbus.ErrTimeout},
+		{Addr: 3, Responded: true, Err: modbus.XIllegalFunc},
+		{Addr: 4, Err: modbus.ErrCRC},
+		{Addr: 5, Responded: true},
+		{Addr: 6, Err: errLinkDown},
+	}
+	if !reflect.DeepEqual(list, want) {
+		t.Errorf("got %+v\nwant %+v", list, want)
+	}
+
+	list, err = modbus.ScanDevicesReport(scanBus(), 254, 255, testDevice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || !list[1].Responded {
+		t.Errorf("got %+v", list)
+	}
+}
+
+func TestScanDevices(t *testing.T) {
+	var found []byte
+	test := func(addr byte, d modbus.Device) error {
+		err := testDevice(addr, d)
+		if err == nil {
+			found = append(found, addr)
+		}
+		return err
+	}
+	err := modbus.ScanDevices(scanBus(), 1, 7, test)
+	if err != modbus.XIllegalFunc {
+		t.Errorf("got %v, want XIllegalFunc", err)
+	}
+	if string(found) != "\x01" {
+		t.Errorf("found %v", found)
+	}
+
+	found = nil
+	err = modbus.ScanDevices(scanBus(), 4, 255, test)
+	if err != errLinkDown {
+		t.Errorf("got %v, want %v", err, errLinkDown)
+	}
+	if string(found) != "\x05" {
+		t.Errorf("found %v", found)
+	}
+
+	found = nil
+	err = modbus.ScanDevices(scanBus(), 250, 255, test)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(found) != "\xff" {
+		t.Errorf("found %v", found)
+	}
+}
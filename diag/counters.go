@@ -0,0 +1,70 @@
+package diag
+
+import (
+	"github.com/knieriem/modbus"
+)
+
+func (d *Device) counter(sub SubFunc, opts []modbus.ReqOption) (uint16, error) {
+	return d.Diagnostic(sub, 0, opts...)
+}
+
+// DiagBusMessageCount returns the number of messages
+// the device detected on the bus since its last restart.
+func (d *Device) DiagBusMessageCount(opts ...modbus.ReqOption) (uint16, error) {
+	return d.counter(ReturnBusMessageCount, opts)
+}
+
+// DiagBusCommErrorCount returns the number of CRC errors.
+func (d *Device) DiagBusCommErrorCount(opts ...modbus.ReqOption) (uint16, error) {
+	return d.counter(ReturnBusCommErrorCount, opts)
+}
+
+// DiagBusExceptionErrorCount returns the number of
+// exception responses returned by the device.
+func (d *Device) DiagBusExceptionErrorCount(opts ...modbus.ReqOption) (uint16, error) {
+	return d.counter(ReturnBusExceptionErrorCount, opts)
+}
+
+// DiagServerMessageCount returns the number of messages
+// addressed to the device, or broadcast.
+func (d *Device) DiagServerMessageCount(opts ...modbus.ReqOption) (uint16, error) {
+	return d.counter(ReturnServerMessageCount, opts)
+}
+
+// DiagServerNoResponseCount returns the number of messages
+// addressed to the device, for which it did not send a response.
+func (d *Device) DiagServerNoResponseCount(opts ...modbus.ReqOption) (uint16, error) {
+	return d.counter(ReturnServerNoResponseCount, opts)
+}
+
+// DiagServerNAKCount returns the number of
+// negative acknowledge exception responses.
+func (d *Device) DiagServerNAKCount(opts ...modbus.ReqOption) (uint16, error) {
+	return d.counter(ReturnServerNAKCount, opts)
+}
+
+// DiagServerBusyCount returns the number of
+// device busy exception responses.
+func (d *Device) DiagServerBusyCount(opts ...modbus.ReqOption) (uint16, error) {
+	return d.counter(ReturnServerBusyCount, opts)
+}
+
+// DiagBusCharOverrunCount returns the number of messages the device
+// could not handle due to a character overrun condition.
+func (d *Device) DiagBusCharOverrunCount(opts ...modbus.ReqOption) (uint16, error) {
+	return d.counter(ReturnBusCharOverrunCount, opts)
+}
+
+// ClearCounters clears all counters and the diagnostic register
+// of the device, and verifies that the response echoes
+// the request's zero data field.
+func (d *Device) ClearCounters(opts ...modbus.ReqOption) error {
+	echo, err := d.Diagnostic(ClearCountersAndDiagRegister, 0, opts...)
+	if err != nil {
+		return err
+	}
+	if echo != 0 {
+		return Error("unexpected data in response")
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package diag_test
+
+import (
+	"testing"
+
+	"github.com/knieriem/modbus"
+	"github.com/knieriem/modbus/diag"
+)
+
+// A counterDevice answers Diagnostics requests with a counter value
+// derived from the sub-function, and records the last sub-function.
+// If echo is set, it returns the request's data word instead.
+type counterDevice struct {
+	sub  uint16
+	echo bool
+}
+
+func (d *counterDevice) Request(fn uint8, req modbus.Request, resp modbus.Response, _ ...modbus.ReqOption) error {
+	data, err := modbus.EncodeData(req)
+	if err != nil {
+		return err
+	}
+	if modbus.FuncCode(fn) != modbus.FnDiagnostics || len(data) != 4 {
+		return modbus.XIllegalFunc
+	}
+	d.sub = modbus.ByteOrder.Uint16(data)
+	if !d.echo {
+		modbus.ByteOrder.PutUint16(data[2:], 0x100+d.sub)
+	}
+	return resp.Decode(data)
+}
+
+func TestCounters(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		read  func(*diag.Device, ...modbus.ReqOption) (uint16, error)
+		sub   diag.SubFunc
+		subFn uint16
+	}{
+		{"BusMessageCount", (*diag.Device).DiagBusMessageCount, diag.ReturnBusMessageCount, 0x0B},
+		{"BusCommErrorCount", (*diag.Device).DiagBusCommErrorCount, diag.ReturnBusCommErrorCount, 0x0C},
+		{"BusExceptionErrorCount", (*diag.Device).DiagBusExceptionErrorCount, diag.ReturnBusExceptionErrorCount, 0x0D},
+		{"ServerMessageCount", (*diag.Device).DiagServerMessageCount, diag.ReturnServerMessageCount, 0x0E},
+		{"ServerNoResponseCount", (*diag.Device).DiagServerNoResponseCount, diag.ReturnServerNoResponseCount, 0x0F},
+		{"ServerNAKCount", (*diag.Device).DiagServerNAKCount, diag.ReturnServerNAKCount, 0x10},
+		{"ServerBusyCount", (*diag.Device).DiagServerBusyCount, diag.ReturnServerBusyCount, 0x11},
+		{"BusCharOverrunCount", (*diag.Device).DiagBusCharOverrunCount, diag.ReturnBusCharOverrunCount, 0x12},
+	} {
+		if uint16(tc.sub) != tc.subFn {
+			t.Errorf("%s: sub-function constant is %#x, want %#x", tc.name, tc.sub, tc.subFn)
+		}
+		cd := new(counterDevice)
+		v, err := tc.read(diag.NewDevice(cd))
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if cd.sub != tc.subFn {
+			t.Errorf("%s: sent sub-function %#x, want %#x", tc.name, cd.sub, tc.subFn)
+		}
+		if v != 0x100+tc.subFn {
+			t.Errorf("%s: got %#x", tc.name, v)
+		}
+	}
+}
+
+func TestClearCounters(t *testing.T) {
+	cd := &counterDevice{echo: true}
+	err := diag.NewDevice(cd).ClearCounters()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cd.sub != 0x0A {
+		t.Errorf("sent sub-function %#x, want 0x0a", cd.sub)
+	}
+	cd.echo = false
+	err = diag.NewDevice(cd).ClearCounters()
+	if err == nil {
+		t.Error("non-zero data in response accepted")
+	}
+}
@@ -0,0 +1,125 @@
+// Package diag implements the Modbus Diagnostics function,
+// and related functions, like Report Server ID (serial line only)
+package diag
+
+import (
+	"encoding/binary"
+	"io"
+
+	"github.com/knieriem/modbus"
+)
+
+type Error string
+
+func (e Error) Error() string {
+	return "diag: " + string(e)
+}
+
+type SubFunc uint16
+
+const (
+	ReturnQueryData       SubFunc = 0x00
+	RestartCommOption     SubFunc = 0x01
+	ReturnDiagRegister    SubFunc = 0x02
+	ChangeASCIIInputDelim SubFunc = 0x03
+	ForceListenOnly       SubFunc = 0x04
+
+	ClearCountersAndDiagRegister SubFunc = 0x0A
+	ReturnBusMessageCount        SubFunc = 0x0B
+	ReturnBusCommErrorCount      SubFunc = 0x0C
+	ReturnBusExceptionErrorCount SubFunc = 0x0D
+	ReturnServerMessageCount     SubFunc = 0x0E
+	ReturnServerNoResponseCount  SubFunc = 0x0F
+	ReturnServerNAKCount         SubFunc = 0x10
+	ReturnServerBusyCount        SubFunc = 0x11
+	ReturnBusCharOverrunCount    SubFunc = 0x12
+)
+
+type Device struct {
+	modbus.Device
+
+	// ServerIDLen is the device specific length of the server ID
+	// contained in a Report Server ID response; it defaults to one.
+	ServerIDLen int
+
+	// ListenOnly tells whether the device is assumed to be
+	// in listen only mode. It is set after a ForceListenOnly request
+	// and cleared after a Restart Communications Option request;
+	// it may be set explicitly, in case the device has been
+	// put into listen only mode by other means.
+	ListenOnly bool
+}
+
+func NewDevice(d modbus.Device) *Device {
+	return &Device{Device: d, ServerIDLen: 1}
+}
+
+type msg struct {
+	SubFunc SubFunc
+	Data    uint16
+}
+
+func (m *msg) Encode(w io.Writer) error {
+	return binary.Write(w, modbus.ByteOrder, m)
+}
+
+func (m *msg) Decode(buf []byte) error {
+	if len(buf) != 4 {
+		return modbus.NewInvalidLen(modbus.MsgContextData, len(buf), 4)
+	}
+	if SubFunc(modbus.ByteOrder.Uint16(buf)) != m.SubFunc {
+		return Error("sub-function mismatch")
+	}
+	m.Data = modbus.ByteOrder.Uint16(buf[2:])
+	return nil
+}
+
+// Diagnostic sends a Diagnostics request using the specified
+// sub-function and data word, and returns the data word of the response.
+//
+// In case of ForceListenOnly, no response is expected, since the device
+// stops responding after having received the request. The device
+// will remain in listen only mode until it is restarted, or power-cycled,
+// or until it receives a Restart Communications Option request.
+// The same applies to RestartCommOption, if d.ListenOnly is set.
+func (d *Device) Diagnostic(sub SubFunc, data uint16, opts ...modbus.ReqOption) (uint16, error) {
+	resp := &msg{SubFunc: sub}
+	if sub == ForceListenOnly || (sub == RestartCommOption && d.ListenOnly) {
+		opts = append(opts, modbus.ExpectNoResponse())
+	} else {
+		opts = append(opts, modbus.ExpectedRespLen(1+2+2))
+	}
+	err := d.Request(uint8(modbus.FnDiagnostics), &msg{SubFunc: sub, Data: data}, resp, opts...)
+	if err != nil {
+		return 0, err
+	}
+	switch sub {
+	case ForceListenOnly:
+		d.ListenOnly = true
+	case RestartCommOption:
+		d.ListenOnly = false
+	}
+	return resp.Data, nil
+}
+
+// RestartComm sends a Restart Communications Option request,
+// which restarts the serial line port of the device, and brings
+// it out of listen only mode. If clearLog is true, the communications
+// event log is cleared too. If the device is in listen only mode,
+// as recorded in d.ListenOnly, it will not send a response;
+// in this case RestartComm does not wait for one.
+func (d *Device) RestartComm(clearLog bool, opts ...modbus.ReqOption) error {
+	var data uint16
+	if clearLog {
+		data = 0xFF00
+	}
+	expectResp := !d.ListenOnly
+	echo, err := d.Diagnostic(RestartCommOption, data, opts...)
+	if err != nil {
+		return err
+	}
+	if expectResp && echo != data {
+		return Error("unexpected data in response")
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package diag_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/knieriem/modbus"
+	"github.com/knieriem/modbus/diag"
+	"github.com/knieriem/modbus/internal/mocknet"
+)
+
+// newDiagDevice returns a diag.Device on top of a mock connection,
+// which answers Diagnostics requests using respData
+// as the response's data word. If respond is false, no
+// response is sent.
+func newDiagDevice(respond *bool, respData *uint16) (*diag.Device, *mocknet.Conn) {
+	nc := mocknet.New(func(_ context.Context, req []byte, _ *modbus.ExpectedRespLenSpec) ([]byte, error) {
+		if !*respond {
+			return nil, nil
+		}
+		resp := append([]byte(nil), req[:4]...)
+		return append(resp, byte(*respData>>8), byte(*respData)), nil
+	})
+	netw := modbus.NewNetwork(nc)
+	netw.TurnaroundDelay = 0
+	return diag.NewDevice(mocknet.Device(netw, 1)), nc
+}
+
+func TestForceListenOnly(t *testing.T) {
+	respond := false
+	var data uint16
+	d, nc := newDiagDevice(&respond, &data)
+	_, err := d.Diagnostic(diag.ForceListenOnly, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nc.Sent) != 1 {
+		t.Fatalf("%d requests sent, want 1", len(nc.Sent))
+	}
+	if len(nc.Specs) != 0 {
+		t.Error("response has been received")
+	}
+	if !d.ListenOnly {
+		t.Error("ListenOnly not set")
+	}
+}
+
+func TestRestartComm(t *testing.T) {
+	respond := true
+	var data uint16 = 0xFF00
+	d, nc := newDiagDevice(&respond, &data)
+	if err := d.RestartComm(true); err != nil {
+		t.Fatal(err)
+	}
+	if len(nc.Specs) != 1 {
+		t.Errorf("%d responses received, want 1", len(nc.Specs))
+	}
+	data = 0
+	if err := d.RestartComm(true); err == nil {
+		t.Error("echo mismatch not detected")
+	}
+
+	// In listen only mode, the device does not respond.
+	respond = false
+	d.ListenOnly = true
+	nc.Specs = nil
+	if err := d.RestartComm(false); err != nil {
+		t.Fatal(err)
+	}
+	if len(nc.Specs) != 0 {
+		t.Error("response has been received")
+	}
+	if d.ListenOnly {
+		t.Error("ListenOnly not cleared")
+	}
+}
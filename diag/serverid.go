@@ -0,0 +1,93 @@
+package diag
+
+import (
+	"time"
+
+	"github.com/knieriem/modbus"
+)
+
+// ServerID contains the decoded response of a Report Server ID request.
+type ServerID struct {
+	ID      []byte
+	Running bool
+	Data    []byte
+}
+
+type serverIDResp struct {
+	idLen int
+	ServerID
+}
+
+func (r *serverIDResp) Decode(buf []byte) error {
+	if len(buf) < 1 {
+		return modbus.NewInvalidLen(modbus.MsgContextData, len(buf), 1)
+	}
+	data := buf[1:]
+	if int(buf[0]) != len(data) {
+		return modbus.NewLengthFieldMismatch(int(buf[0]), len(data))
+	}
+	if len(data) < r.idLen+1 {
+		return modbus.NewInvalidLen(modbus.MsgContextData, len(buf), 1+r.idLen+1)
+	}
+	switch data[r.idLen] {
+	case 0x00:
+	case 0xFF:
+		r.Running = true
+	default:
+		return Error("invalid run indicator status")
+	}
+	r.ID = append([]byte(nil), data[:r.idLen]...)
+	r.Data = append([]byte(nil), data[r.idLen+1:]...)
+	return nil
+}
+
+// ReportServerID reads the server ID, the run indicator status,
+// and additional data of a device. The length of the
+// server ID is taken from d.ServerIDLen.
+func (d *Device) ReportServerID(opts ...modbus.ReqOption) (*ServerID, error) {
+	resp := &serverIDResp{idLen: d.ServerIDLen}
+	vs := &modbus.VariableRespLenSpec{
+		NumItemsFixed: 1,
+		ItemLenIndex:  1,
+	}
+	opts = append(opts, modbus.VariableRespLen(vs))
+	err := d.Request(uint8(modbus.FnReportServerID), nil, resp, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &resp.ServerID, nil
+}
+
+var ErrNotRunning = Error("device not running")
+
+// WaitRunning polls the run indicator status of a device using
+// ReportServerID, until it reports that the device is running,
+// or until the timeout expires, in which case ErrNotRunning is returned.
+// The interval between polls starts at 50ms, and is doubled
+// after each poll, up to one second. Transient errors, like timeouts,
+// are ignored, since a device may not respond while starting.
+func (d *Device) WaitRunning(timeout time.Duration, opts ...modbus.ReqOption) error {
+	tEnd := time.Now().Add(timeout)
+	delay := 50 * time.Millisecond
+	for {
+		id, err := d.ReportServerID(opts...)
+		if err == nil {
+			if id.Running {
+				return nil
+			}
+		} else if !modbus.IsTransient(err) {
+			return err
+		}
+		remain := time.Until(tEnd)
+		if remain <= 0 {
+			return ErrNotRunning
+		}
+		if delay > remain {
+			delay = remain
+		}
+		time.Sleep(delay)
+		if delay *= 2; delay > time.Second {
+			delay = time.Second
+		}
+	}
+}
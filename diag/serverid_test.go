@@ -0,0 +1,65 @@
+package diag_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/knieriem/modbus"
+	"github.com/knieriem/modbus/diag"
+)
+
+// A startingDevice answers Report Server ID requests, reporting
+// the run indicator status OFF for the first nOff polls. The
+// first poll times out, like with a device still booting.
+type startingDevice struct {
+	nOff  int
+	polls []time.Time
+}
+
+func (d *startingDevice) Request(fn uint8, _ modbus.Request, resp modbus.Response, _ ...modbus.ReqOption) error {
+	if modbus.FuncCode(fn) != modbus.FnReportServerID {
+		return modbus.XIllegalFunc
+	}
+	d.polls = append(d.polls, time.Now())
+	n := len(d.polls)
+	if n == 1 {
+		return modbus.ErrTimeout
+	}
+	run := byte(0xFF)
+	if n <= d.nOff {
+		run = 0x00
+	}
+	return resp.Decode([]byte{2, 0x42, run})
+}
+
+func TestWaitRunning(t *testing.T) {
+	sd := &startingDevice{nOff: 3}
+	err := diag.NewDevice(sd).WaitRunning(5 * time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(sd.polls); n != 4 {
+		t.Fatalf("%d polls, want 4", n)
+	}
+	for i := 2; i < len(sd.polls); i++ {
+		prev := sd.polls[i-1].Sub(sd.polls[i-2])
+		if d := sd.polls[i].Sub(sd.polls[i-1]); d < 2*prev*9/10 {
+			t.Errorf("poll interval %d not doubled: %v after %v", i, d, prev)
+		}
+	}
+}
+
+func TestWaitRunningTimeout(t *testing.T) {
+	sd := &startingDevice{nOff: 1000}
+	t0 := time.Now()
+	err := diag.NewDevice(sd).WaitRunning(120 * time.Millisecond)
+	if err != diag.ErrNotRunning {
+		t.Fatalf("got %v, want ErrNotRunning", err)
+	}
+	if d := time.Since(t0); d < 120*time.Millisecond || d > time.Second {
+		t.Errorf("returned after %v", d)
+	}
+	if n := len(sd.polls); n < 3 {
+		t.Errorf("%d polls, want at least 3", n)
+	}
+}
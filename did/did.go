@@ -5,6 +5,8 @@ import (
 	"bytes"
 	"encoding/binary"
 	"io"
+	"sync"
+	"time"
 	"unicode/utf8"
 
 	"github.com/knieriem/modbus"
@@ -71,11 +73,42 @@ var names = []string{
 
 type Reader struct {
 	tp *mei.Transport
+
+	maxObjects int
+	maxBytes   int
+}
+
+type ReaderOption func(*Reader)
+
+// WithMaxObjects limits the number of objects returned by a single
+// call to Read, including continuations. The default is 256, which
+// corresponds to the number of possible object IDs.
+// The limits are checked for each response before the object data
+// is copied; if a limit is exceeded, Read returns ErrLimitExceeded
+// and no objects.
+func WithMaxObjects(n int) ReaderOption {
+	return func(r *Reader) {
+		r.maxObjects = n
+	}
 }
 
-func NewReader(d modbus.Device) *Reader {
+// WithMaxBytes limits the total size of the object data
+// returned by a single call to Read. By default there is no limit.
+func WithMaxBytes(n int) ReaderOption {
+	return func(r *Reader) {
+		r.maxBytes = n
+	}
+}
+
+var ErrLimitExceeded = Error("object limit exceeded")
+
+func NewReader(d modbus.Device, opts ...ReaderOption) *Reader {
 	r := new(Reader)
 	r.tp = mei.NewTransport(d, 14)
+	r.maxObjects = 256
+	for _, o := range opts {
+		o(r)
+	}
 	return r
 }
 
@@ -96,8 +129,27 @@ func (r *Reader) ReadObject(id ID, reqOpts ...modbus.ReqOption) (o Object, err e
 	return
 }
 
+// ReadCategory reads all objects of the specified category,
+// starting with the first object of that category, i.e. 0x00 for
+// Basic, 0x03 for Regular, and 0x80 for Extended. Objects
+// of lower categories are not included.
+func (r *Reader) ReadCategory(cat Category, reqOpts ...modbus.ReqOption) ([]Object, error) {
+	return r.Read(cat, cat.firstID(), reqOpts...)
+}
+
+func (cat Category) firstID() ID {
+	switch cat {
+	case Regular:
+		return VendorURL
+	case Extended:
+		return 0x80
+	}
+	return 0
+}
+
 func (r *Reader) Read(cat Category, startID ID, reqOpts ...modbus.ReqOption) (list []Object, err error) {
 	forceID := false
+	nBytes := 0
 more:
 	req := []byte{byte(cat), byte(startID)}
 	vs := &modbus.VariableRespLenSpec{
@@ -134,6 +186,18 @@ more:
 		}
 	}
 
+	// Check the limits before any object data is copied.
+	if len(list)+int(h.NObj) > r.maxObjects {
+		return nil, ErrLimitExceeded
+	}
+	n, err := objectBytes(data, int(h.NObj))
+	if err != nil {
+		return nil, err
+	}
+	nBytes += n
+	if r.maxBytes != 0 && nBytes > r.maxBytes {
+		return nil, ErrLimitExceeded
+	}
 	var o Object
 	for i := 0; i < int(h.NObj); i++ {
 		data, err = parseObject(&o, data)
@@ -148,10 +212,6 @@ more:
 		}
 		list = append(list, o)
 	}
-	if len(data) != 0 {
-		err = Error("unexpected trailing bytes")
-		return
-	}
 	if h.MoreFollows != 0 {
 		forceID = true
 		startID = ID(h.NextObjID)
@@ -160,6 +220,27 @@ more:
 	return
 }
 
+// objectBytes returns the total size of the data of n objects
+// contained in data, which must not contain trailing bytes.
+func objectBytes(data []byte, n int) (nBytes int, err error) {
+	for i := 0; i < n; i++ {
+		if len(data) < 2 {
+			return 0, Error("not enough bytes to parse an object")
+		}
+		l := int(data[1])
+		data = data[2:]
+		if len(data) < l {
+			return 0, Error("invalid number of object bytes")
+		}
+		data = data[l:]
+		nBytes += l
+	}
+	if len(data) != 0 {
+		return 0, Error("unexpected trailing bytes")
+	}
+	return nBytes, nil
+}
+
 func parseObject(o *Object, data []byte) (tail []byte, err error) {
 	if len(data) < 2 {
 		err = Error("not enough bytes to parse an object")
@@ -181,3 +262,55 @@ func parseObject(o *Object, data []byte) (tail []byte, err error) {
 	copy(o.Data, data)
 	return
 }
+
+// A CachedReader caches the objects read per category.
+// Cache entries expire after TTL; a TTL of zero means that
+// entries never expire. Invalidate removes all entries,
+// e.g. after a device has been replaced.
+// A CachedReader may be used by multiple goroutines.
+type CachedReader struct {
+	r   *Reader
+	TTL time.Duration
+
+	mu    sync.Mutex
+	cache map[Category]cacheEntry
+}
+
+type cacheEntry struct {
+	t    time.Time
+	list []Object
+}
+
+func NewCachedReader(r *Reader, ttl time.Duration) *CachedReader {
+	c := new(CachedReader)
+	c.r = r
+	c.TTL = ttl
+	c.cache = make(map[Category]cacheEntry, 3)
+	return c
+}
+
+// ReadCategory returns the objects of the specified category
+// from the cache, if present, otherwise it reads them from the device.
+// The returned list must not be modified.
+func (c *CachedReader) ReadCategory(cat Category, reqOpts ...modbus.ReqOption) ([]Object, error) {
+	c.mu.Lock()
+	e, ok := c.cache[cat]
+	c.mu.Unlock()
+	if ok && (c.TTL == 0 || time.Since(e.t) < c.TTL) {
+		return e.list, nil
+	}
+	list, err := c.r.ReadCategory(cat, reqOpts...)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.cache[cat] = cacheEntry{t: time.Now(), list: list}
+	c.mu.Unlock()
+	return list, nil
+}
+
+func (c *CachedReader) Invalidate() {
+	c.mu.Lock()
+	c.cache = make(map[Category]cacheEntry, 3)
+	c.mu.Unlock()
+}
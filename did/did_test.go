@@ -0,0 +1,144 @@
+package did_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/knieriem/modbus"
+	"github.com/knieriem/modbus/did"
+	"github.com/knieriem/modbus/internal/mocknet"
+)
+
+// respFrame returns the response frame to a Read Device Identification
+// request frame, containing objs. If next is not zero, the response
+// announces that more objects follow, starting with next.
+func respFrame(req []byte, next did.ID, objs ...did.Object) []byte {
+	b := []byte{req[0], req[1], req[2], req[3], 0x83, 0, byte(next), byte(len(objs))}
+	if next != 0 {
+		b[5] = 0xFF
+	}
+	for _, o := range objs {
+		b = append(b, byte(o.ID), byte(len(o.Data)))
+		b = append(b, o.Data...)
+	}
+	return b
+}
+
+func obj(id did.ID, s string) did.Object {
+	return did.Object{ID: id, Data: []byte(s)}
+}
+
+func TestReadMaxObjects(t *testing.T) {
+	nc := mocknet.New(func(_ context.Context, req []byte, _ *modbus.ExpectedRespLenSpec) ([]byte, error) {
+		return respFrame(req, 0, obj(0, "v"), obj(1, "p"), obj(2, "1.0")), nil
+	})
+	r := did.NewReader(mocknet.Device(modbus.NewNetwork(nc), 1), did.WithMaxObjects(2))
+	list, err := r.Read(did.Basic, 0)
+	if err != did.ErrLimitExceeded {
+		t.Fatalf("got %v, want ErrLimitExceeded", err)
+	}
+	if list != nil {
+		t.Errorf("objects returned: %v", list)
+	}
+
+	r = did.NewReader(mocknet.Device(modbus.NewNetwork(nc), 1), did.WithMaxObjects(3))
+	list, err = r.Read(did.Basic, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 3 {
+		t.Errorf("got %d objects, want 3", len(list))
+	}
+}
+
+func TestReadMaxBytes(t *testing.T) {
+	nc := mocknet.New(func(_ context.Context, req []byte, _ *modbus.ExpectedRespLenSpec) ([]byte, error) {
+		if req[4] == 0 {
+			return respFrame(req, 2, obj(0, "abc"), obj(1, "def")), nil
+		}
+		return respFrame(req, 0, obj(2, "0123456789")), nil
+	})
+	r := did.NewReader(mocknet.Device(modbus.NewNetwork(nc), 1), did.WithMaxBytes(10))
+	list, err := r.Read(did.Basic, 0)
+	if err != did.ErrLimitExceeded {
+		t.Fatalf("got %v, want ErrLimitExceeded", err)
+	}
+	if list != nil {
+		t.Errorf("objects returned: %v", list)
+	}
+	if n := len(nc.Sent); n != 2 {
+		t.Errorf("%d requests sent, want 2", n)
+	}
+
+	r = did.NewReader(mocknet.Device(modbus.NewNetwork(nc), 1), did.WithMaxBytes(16))
+	list, err = r.Read(did.Basic, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 3 || list[2].String() != "0123456789" {
+		t.Errorf("unexpected objects: %v", list)
+	}
+}
+
+func TestReadCategoryStartID(t *testing.T) {
+	nc := mocknet.New(func(_ context.Context, req []byte, _ *modbus.ExpectedRespLenSpec) ([]byte, error) {
+		return respFrame(req, 0, obj(did.ID(req[4]), "x")), nil
+	})
+	r := did.NewReader(mocknet.Device(modbus.NewNetwork(nc), 1))
+	for _, tc := range []struct {
+		cat   did.Category
+		start did.ID
+	}{
+		{did.Basic, 0x00},
+		{did.Regular, 0x03},
+		{did.Extended, 0x80},
+	} {
+		list, err := r.ReadCategory(tc.cat)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req := nc.Sent[len(nc.Sent)-1]
+		if did.Category(req[3]) != tc.cat || did.ID(req[4]) != tc.start {
+			t.Errorf("category %d: request % x, want start ID %#x", tc.cat, req, tc.start)
+		}
+		if len(list) != 1 || list[0].ID != tc.start {
+			t.Errorf("category %d: unexpected objects: %v", tc.cat, list)
+		}
+	}
+}
+
+func TestCachedReader(t *testing.T) {
+	n := 0
+	nc := mocknet.New(func(_ context.Context, req []byte, _ *modbus.ExpectedRespLenSpec) ([]byte, error) {
+		n++
+		return respFrame(req, 0, obj(0, "vendor")), nil
+	})
+	r := did.NewReader(mocknet.Device(modbus.NewNetwork(nc), 1))
+	c := did.NewCachedReader(r, 50*time.Millisecond)
+
+	read := func(cat did.Category, wantN int) {
+		t.Helper()
+		list, err := c.ReadCategory(cat)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(list) != 1 || list[0].String() != "vendor" {
+			t.Errorf("unexpected objects: %v", list)
+		}
+		if n != wantN {
+			t.Errorf("%d requests sent, want %d", n, wantN)
+		}
+	}
+	read(did.Basic, 1)
+	read(did.Basic, 1)
+	read(did.Regular, 2)
+
+	time.Sleep(60 * time.Millisecond)
+	read(did.Basic, 3)
+	read(did.Basic, 3)
+
+	c.Invalidate()
+	read(did.Basic, 4)
+	read(did.Regular, 5)
+}
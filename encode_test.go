@@ -0,0 +1,71 @@
+package modbus_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/knieriem/modbus"
+	"github.com/knieriem/modbus/register"
+)
+
+type readRegsReq struct {
+	Start uint16
+	N     uint16
+}
+
+func (r *readRegsReq) Encode(w io.Writer) error {
+	return binary.Write(w, modbus.ByteOrder, r)
+}
+
+type failingReq struct{}
+
+var errEncode = errors.New("encode failed")
+
+func (failingReq) Encode(io.Writer) error {
+	return errEncode
+}
+
+// A captureDevice records the data part of each request.
+type captureDevice struct {
+	fn   uint8
+	data []byte
+}
+
+func (d *captureDevice) Request(fn uint8, req modbus.Request, _ modbus.Response, _ ...modbus.ReqOption) (err error) {
+	d.fn = fn
+	d.data, err = modbus.EncodeData(req)
+	return err
+}
+
+func TestEncodeData(t *testing.T) {
+	// read holding registers request from the Modbus
+	// application protocol specification: 03 00 6B 00 03
+	b, err := modbus.EncodeData(&readRegsReq{Start: 0x6B, N: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte{0x00, 0x6B, 0x00, 0x03}; !bytes.Equal(b, want) {
+		t.Errorf("got % x, want % x", b, want)
+	}
+
+	// write multiple registers request from the specification:
+	// 10 00 01 00 02 04 00 0A 01 02
+	d := new(captureDevice)
+	register.NewDevice(d).WriteRegs(1, []uint16{0x000A, 0x0102})
+	want := []byte{0x00, 0x01, 0x00, 0x02, 0x04, 0x00, 0x0A, 0x01, 0x02}
+	if d.fn != 0x10 || !bytes.Equal(d.data, want) {
+		t.Errorf("got %02x % x, want 10 % x", d.fn, d.data, want)
+	}
+
+	b, err = modbus.EncodeData(nil)
+	if b != nil || err != nil {
+		t.Errorf("nil request: got % x, %v", b, err)
+	}
+	_, err = modbus.EncodeData(failingReq{})
+	if err != errEncode {
+		t.Errorf("got %v, want %v", err, errEncode)
+	}
+}
@@ -0,0 +1,94 @@
+// Package vendorfunc is an example of how to implement
+// a vendor specific function on top of modbus.Device.
+//
+// The function implemented here, Read Extension Block, uses the
+// user defined function code 0x41. The request contains the number
+// of the block to be read:
+//
+//	41 <block>
+//
+// The response repeats the block number, followed by a byte count,
+// and the block data, consisting of type-length-value items:
+//
+//	41 <block> <byte count> <type> <len> <value> ...
+//
+// A request type implements modbus.Request, a response type
+// modbus.Response. By also implementing an ExpectedLenSpec method,
+// the response tells the Network how to detect a complete response
+// frame, so that a request returns as early as possible.
+package vendorfunc
+
+import (
+	"io"
+
+	"github.com/knieriem/modbus"
+)
+
+// FnReadExtBlock is the function code of Read Extension Block.
+const FnReadExtBlock = 0x41
+
+type Error string
+
+func (e Error) Error() string {
+	return "vendorfunc: " + string(e)
+}
+
+type Device struct {
+	modbus.Device
+}
+
+func NewDevice(d modbus.Device) *Device {
+	return &Device{Device: d}
+}
+
+type readExtBlockReq struct {
+	block uint8
+}
+
+func (r *readExtBlockReq) Encode(w io.Writer) error {
+	_, err := w.Write([]byte{r.block})
+	return err
+}
+
+type readExtBlockResp struct {
+	block uint8
+	modbus.TLVDecoder
+}
+
+// ExpectedLenSpec returns a spec describing the response PDU
+// as a single item, the length of which is stored in the byte
+// count field at index 2, following the function code and the
+// block number.
+func (r *readExtBlockResp) ExpectedLenSpec() *modbus.ExpectedRespLenSpec {
+	return &modbus.ExpectedRespLenSpec{
+		Variable: &modbus.VariableRespLenSpec{
+			NumItemsFixed: 1,
+			ItemLenIndex:  2,
+		},
+	}
+}
+
+func (r *readExtBlockResp) Decode(buf []byte) error {
+	if len(buf) < 2 {
+		return modbus.NewInvalidLen(modbus.MsgContextData, len(buf), 2)
+	}
+	if buf[0] != r.block {
+		return Error("block number mismatch")
+	}
+	data := buf[2:]
+	if int(buf[1]) != len(data) {
+		return modbus.NewLengthFieldMismatch(int(buf[1]), len(data))
+	}
+	return r.TLVDecoder.Decode(data)
+}
+
+// ReadExtBlock reads the specified extension block,
+// and returns the items it consists of.
+func (d *Device) ReadExtBlock(block uint8, opts ...modbus.ReqOption) ([]modbus.TLV, error) {
+	resp := &readExtBlockResp{block: block}
+	err := d.Request(FnReadExtBlock, &readExtBlockReq{block: block}, resp, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Items, nil
+}
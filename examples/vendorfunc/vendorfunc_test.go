@@ -0,0 +1,65 @@
+package vendorfunc_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/knieriem/modbus"
+	"github.com/knieriem/modbus/examples/vendorfunc"
+	"github.com/knieriem/modbus/internal/mocknet"
+)
+
+func TestReadExtBlock(t *testing.T) {
+	// block 3: items 0x10: ab cd, and 0x20: 01
+	pdu := []byte{vendorfunc.FnReadExtBlock, 3, 7, 0x10, 2, 0xAB, 0xCD, 0x20, 1, 0x01}
+
+	conn := mocknet.New(func(_ context.Context, req []byte, _ *modbus.ExpectedRespLenSpec) ([]byte, error) {
+		return append([]byte{req[0]}, pdu...), nil
+	})
+	d := vendorfunc.NewDevice(mocknet.Device(modbus.NewNetwork(conn), 1))
+
+	items, err := d.ReadExtBlock(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []byte{1, vendorfunc.FnReadExtBlock, 3}; !bytes.Equal(conn.Sent[0], want) {
+		t.Errorf("request: got % x, want % x", conn.Sent[0], want)
+	}
+
+	ls := conn.Specs[0]
+	if ls == nil {
+		t.Fatal("no expected length spec passed to the transport")
+	}
+	if err := ls.CheckLen(pdu); err != nil {
+		t.Errorf("complete response not accepted: %v", err)
+	}
+	for n := 1; n < len(pdu); n++ {
+		if ls.CheckLen(pdu[:n]) == nil {
+			t.Errorf("partial response of length %d accepted", n)
+		}
+	}
+
+	if len(items) != 2 {
+		t.Fatalf("got %d items, want 2", len(items))
+	}
+	if it := items[0]; it.Type != 0x10 || !bytes.Equal(it.Value, []byte{0xAB, 0xCD}) {
+		t.Errorf("item 0: got %x % x", it.Type, it.Value)
+	}
+	if it := items[1]; it.Type != 0x20 || !bytes.Equal(it.Value, []byte{0x01}) {
+		t.Errorf("item 1: got %x % x", it.Type, it.Value)
+	}
+}
+
+func TestReadExtBlockMismatch(t *testing.T) {
+	conn := mocknet.New(func(_ context.Context, req []byte, _ *modbus.ExpectedRespLenSpec) ([]byte, error) {
+		return []byte{req[0], vendorfunc.FnReadExtBlock, 4, 0}, nil
+	})
+	d := vendorfunc.NewDevice(mocknet.Device(modbus.NewNetwork(conn), 1))
+
+	_, err := d.ReadExtBlock(3)
+	if err != vendorfunc.Error("block number mismatch") {
+		t.Errorf("got error %v", err)
+	}
+}
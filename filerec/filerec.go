@@ -0,0 +1,132 @@
+// Package filerec implements the Modbus Read File Record function
+package filerec
+
+import (
+	"encoding/binary"
+	"io"
+
+	"github.com/knieriem/modbus"
+)
+
+type Error string
+
+func (e Error) Error() string {
+	return "filerec: " + string(e)
+}
+
+const (
+	refType = 6
+
+	subReqLen  = 7
+	maxDataLen = 0xF5 // max. value of the byte count fields
+)
+
+// A Ref specifies a number of registers (Len) to be read from
+// a file, starting at the specified record number.
+type Ref struct {
+	File   uint16
+	Record uint16
+	Len    uint16
+}
+
+func (ref *Ref) respLen() int {
+	return 1 + 1 + 2*int(ref.Len)
+}
+
+type Reader struct {
+	modbus.Device
+}
+
+func NewReader(d modbus.Device) *Reader {
+	return &Reader{Device: d}
+}
+
+type readReq []Ref
+
+func (r readReq) Encode(w io.Writer) (err error) {
+	_, err = w.Write([]byte{byte(len(r) * subReqLen)})
+	if err != nil {
+		return
+	}
+	for i := range r {
+		ref := &r[i]
+		_, err = w.Write([]byte{refType})
+		if err != nil {
+			return
+		}
+		err = binary.Write(w, modbus.ByteOrder, ref)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+type readResp struct {
+	refs []Ref
+	list [][]byte
+}
+
+func (r *readResp) Decode(buf []byte) error {
+	if len(buf) < 1 {
+		return modbus.NewInvalidLen(modbus.MsgContextData, len(buf), 1)
+	}
+	data := buf[1:]
+	if int(buf[0]) != len(data) {
+		return modbus.NewLengthFieldMismatch(int(buf[0]), len(data))
+	}
+	for i := range r.refs {
+		if len(data) < 2 {
+			return Error("not enough bytes to parse a sub-response")
+		}
+		n := int(data[0])
+		if data[1] != refType {
+			return Error("invalid reference type")
+		}
+		if n != 1+2*int(r.refs[i].Len) || len(data) < 1+n {
+			return Error("invalid sub-response length")
+		}
+		rec := make([]byte, n-1)
+		copy(rec, data[2:1+n])
+		r.list = append(r.list, rec)
+		data = data[1+n:]
+	}
+	if len(data) != 0 {
+		return Error("unexpected trailing bytes")
+	}
+	return nil
+}
+
+// Read reads the records specified by refs, and returns
+// the record data for each Ref. If the refs do not fit into
+// a single request or response PDU, they are spread over
+// multiple requests. In case of an error, the records
+// read successfully so far are returned together with the error.
+func (r *Reader) Read(refs []Ref, opts ...modbus.ReqOption) (list [][]byte, err error) {
+	for len(refs) != 0 {
+		n, respLen := 0, 0
+		for _, ref := range refs {
+			if (n+1)*subReqLen > maxDataLen {
+				break
+			}
+			l := ref.respLen()
+			if respLen+l > maxDataLen {
+				break
+			}
+			respLen += l
+			n++
+		}
+		if n == 0 {
+			return list, Error("record length exceeds the response size")
+		}
+		resp := &readResp{refs: refs[:n]}
+		o := append(opts[:len(opts):len(opts)], modbus.VariableRespLen(modbus.VarLenFileRecord()))
+		err = r.Request(uint8(modbus.FnReadFileRecord), readReq(refs[:n]), resp, o...)
+		if err != nil {
+			return list, err
+		}
+		list = append(list, resp.list...)
+		refs = refs[n:]
+	}
+	return list, nil
+}
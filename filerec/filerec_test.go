@@ -0,0 +1,132 @@
+package filerec_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/knieriem/modbus"
+	"github.com/knieriem/modbus/filerec"
+	"github.com/knieriem/modbus/internal/mocknet"
+)
+
+// fileDevice answers Read File Record requests; each register
+// contains the low byte of the file number, and of its record number.
+func fileDevice(_ context.Context, req []byte, _ *modbus.ExpectedRespLenSpec) ([]byte, error) {
+	if req[1] != byte(modbus.FnReadFileRecord) || int(req[2]) != len(req)-3 {
+		return []byte{req[0], req[1] | 0x80, byte(modbus.XIllegalDataVal)}, nil
+	}
+	resp := []byte{req[0], req[1], 0}
+	for sub := req[3:]; len(sub) >= 7; sub = sub[7:] {
+		file := modbus.ByteOrder.Uint16(sub[1:])
+		rec := modbus.ByteOrder.Uint16(sub[3:])
+		n := int(modbus.ByteOrder.Uint16(sub[5:]))
+		resp = append(resp, byte(1+2*n), 6)
+		for i := 0; i < n; i++ {
+			resp = append(resp, byte(file), byte(int(rec)+i))
+		}
+	}
+	resp[2] = byte(len(resp) - 3)
+	return resp, nil
+}
+
+func TestRead(t *testing.T) {
+	nc := mocknet.New(fileDevice)
+	r := filerec.NewReader(mocknet.Device(modbus.NewNetwork(nc), 1))
+	list, err := r.Read([]filerec.Ref{
+		{File: 4, Record: 1, Len: 2},
+		{File: 3, Record: 9, Len: 2},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || string(list[0]) != "\x04\x01\x04\x02" || string(list[1]) != "\x03\x09\x03\x0a" {
+		t.Errorf("got % x", list)
+	}
+	if len(nc.Specs) != 1 || nc.Specs[0] == nil || nc.Specs[0].Variable == nil {
+		t.Fatal("no variable length spec passed")
+	}
+}
+
+func TestReadInvalidSubResponse(t *testing.T) {
+	nc := mocknet.New(func(ctx context.Context, req []byte, ls *modbus.ExpectedRespLenSpec) ([]byte, error) {
+		resp, err := fileDevice(ctx, req, ls)
+		// the device returns the record in the wrong size
+		resp = append(resp[:3], 3, 6, 0, 1)
+		resp[2] = 4
+		return resp, err
+	})
+	r := filerec.NewReader(mocknet.Device(modbus.NewNetwork(nc), 1))
+	_, err := r.Read([]filerec.Ref{{File: 4, Record: 1, Len: 2}})
+	var e filerec.Error
+	if !errors.As(err, &e) {
+		t.Errorf("got %v, want a filerec.Error", err)
+	}
+}
+
+func TestReadSplit(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		nRefs   int
+		recLen  uint16
+		nSplits []int
+	}{
+		// limited by the size of the request
+		{"many refs", 40, 1, []int{35, 5}},
+		// limited by the size of the response
+		{"large records", 3, 60, []int{2, 1}},
+	} {
+		nc := mocknet.New(fileDevice)
+		r := filerec.NewReader(mocknet.Device(modbus.NewNetwork(nc), 1))
+		refs := make([]filerec.Ref, tc.nRefs)
+		for i := range refs {
+			refs[i] = filerec.Ref{File: 1, Record: uint16(i), Len: tc.recLen}
+		}
+		list, err := r.Read(refs)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if len(nc.Sent) != len(tc.nSplits) {
+			t.Fatalf("%s: %d transactions, want %d", tc.name, len(nc.Sent), len(tc.nSplits))
+		}
+		for i, req := range nc.Sent {
+			if n := (len(req) - 3) / 7; n != tc.nSplits[i] {
+				t.Errorf("%s: request %d contains %d refs, want %d", tc.name, i, n, tc.nSplits[i])
+			}
+			if len(req)-1 > modbus.MaxPDULen {
+				t.Errorf("%s: request %d too long", tc.name, i)
+			}
+		}
+		if len(list) != len(refs) {
+			t.Fatalf("%s: got %d records", tc.name, len(list))
+		}
+		for i, rec := range list {
+			if len(rec) != 2*int(tc.recLen) || rec[1] != byte(i) {
+				t.Errorf("%s: record %d: % x", tc.name, i, rec)
+			}
+		}
+	}
+}
+
+func TestReadSplitPartial(t *testing.T) {
+	n := 0
+	nc := mocknet.New(func(ctx context.Context, req []byte, ls *modbus.ExpectedRespLenSpec) ([]byte, error) {
+		n++
+		if n == 2 {
+			return []byte{req[0], req[1] | 0x80, byte(modbus.XDeviceFailure)}, nil
+		}
+		return fileDevice(ctx, req, ls)
+	})
+	r := filerec.NewReader(mocknet.Device(modbus.NewNetwork(nc), 1))
+	refs := make([]filerec.Ref, 40)
+	for i := range refs {
+		refs[i] = filerec.Ref{File: 1, Record: uint16(i), Len: 1}
+	}
+	list, err := r.Read(refs)
+	if err != modbus.XDeviceFailure {
+		t.Errorf("got %v, want XDeviceFailure", err)
+	}
+	if len(list) != 35 {
+		t.Errorf("got %d records of the first transaction, want 35", len(list))
+	}
+}
@@ -0,0 +1,25 @@
+package modbus
+
+// FuncCode is the type of Modbus function codes. Since
+// Request and Bus take function codes as uint8 values,
+// constants must be converted when passed to them.
+type FuncCode uint8
+
+const (
+	FnReadCoils          FuncCode = 0x01
+	FnReadDiscreteInputs FuncCode = 0x02
+	FnReadHolding        FuncCode = 0x03
+	FnReadInput          FuncCode = 0x04
+	FnWriteSingleCoil    FuncCode = 0x05
+	FnWriteSingleReg     FuncCode = 0x06
+	FnDiagnostics        FuncCode = 0x08
+	FnGetCommEventCount  FuncCode = 0x0B
+	FnWriteMultiCoils    FuncCode = 0x0F
+	FnWriteMultiRegs     FuncCode = 0x10
+	FnReportServerID     FuncCode = 0x11
+	FnReadFileRecord     FuncCode = 0x14
+	FnWriteFileRecord    FuncCode = 0x15
+	FnMaskWriteReg       FuncCode = 0x16
+	FnReadWriteMultiRegs FuncCode = 0x17
+	FnMEI                FuncCode = 0x2B
+)
@@ -0,0 +1,77 @@
+// Package mocknet provides a modbus.NetConn for tests, that passes
+// each request to a handler function instead of a real transport.
+package mocknet
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"time"
+
+	"github.com/knieriem/modbus"
+)
+
+// A Handler is called with the request frame, consisting of the
+// address and the PDU, and the expected length spec of the
+// request. It returns the response frame in the same format.
+// If both the frame and the error are nil, Receive returns
+// modbus.ErrTimeout.
+type Handler func(ctx context.Context, req []byte, ls *modbus.ExpectedRespLenSpec) ([]byte, error)
+
+type Conn struct {
+	Handler Handler
+
+	// Sent contains copies of all frames sent,
+	// Specs the length specs passed to Receive.
+	Sent  [][]byte
+	Specs []*modbus.ExpectedRespLenSpec
+
+	buf bytes.Buffer
+}
+
+func New(h Handler) *Conn {
+	return &Conn{Handler: h}
+}
+
+func (c *Conn) Name() string {
+	return "mock"
+}
+
+func (c *Conn) MsgWriter() io.Writer {
+	c.buf.Reset()
+	return &c.buf
+}
+
+func (c *Conn) Send() (modbus.ADU, error) {
+	b := append([]byte(nil), c.buf.Bytes()...)
+	c.Sent = append(c.Sent, b)
+	return modbus.ADU{Bytes: b, PDUStart: 1}, nil
+}
+
+func (c *Conn) Receive(ctx context.Context, _ time.Duration, ls *modbus.ExpectedRespLenSpec) (modbus.ADU, error) {
+	c.Specs = append(c.Specs, ls)
+	resp, err := c.Handler(ctx, c.Sent[len(c.Sent)-1], ls)
+	if resp == nil && err == nil {
+		err = modbus.ErrTimeout
+	}
+	return modbus.ADU{Bytes: resp, PDUStart: 1}, err
+}
+
+func (c *Conn) Device() interface{} {
+	return nil
+}
+
+// Device returns a modbus.Device sending requests
+// to the specified address of bus.
+func Device(bus modbus.Bus, addr uint8) modbus.Device {
+	return &device{bus: bus, addr: addr}
+}
+
+type device struct {
+	bus  modbus.Bus
+	addr uint8
+}
+
+func (d *device) Request(fn uint8, req modbus.Request, resp modbus.Response, opts ...modbus.ReqOption) error {
+	return d.bus.Request(d.addr, fn, req, resp, opts...)
+}
@@ -0,0 +1,74 @@
+package modbus
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// A KeepAlive detects a dead link on an otherwise idle connection
+// by periodically calling a probe function, e.g. one that reads
+// a register of a device. It implements Bus, so that requests
+// of the application can be passed through it; these are serialized
+// with the probes, and defer the next probe, since a successful
+// request proves the link is alive. While a probe is in progress,
+// requests of the application are blocked; the duration of such
+// a delay is limited by the response timeout of the underlying Network.
+type KeepAlive struct {
+	bus       Bus
+	interval  time.Duration
+	probe     func(Bus) error
+	onFailure func(error)
+
+	mu    sync.Mutex
+	tLast time.Time
+}
+
+// NewKeepAlive returns a KeepAlive for bus, that calls probe
+// if no request has been issued for the duration of interval.
+// If probe returns an error, onFailure, if not nil, is called.
+func NewKeepAlive(bus Bus, interval time.Duration, probe func(Bus) error, onFailure func(error)) *KeepAlive {
+	return &KeepAlive{
+		bus:       bus,
+		interval:  interval,
+		probe:     probe,
+		onFailure: onFailure,
+		tLast:     time.Now(),
+	}
+}
+
+func (k *KeepAlive) Request(addr, fn uint8, req Request, resp Response, opts ...ReqOption) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	err := k.bus.Request(addr, fn, req, resp, opts...)
+	k.tLast = time.Now()
+	return err
+}
+
+// Run calls the probe function whenever the connection has been
+// idle for the configured interval. It returns when ctx is cancelled.
+func (k *KeepAlive) Run(ctx context.Context) {
+	t := time.NewTimer(k.interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		k.mu.Lock()
+		idle := time.Since(k.tLast)
+		if idle < k.interval {
+			k.mu.Unlock()
+			t.Reset(k.interval - idle)
+			continue
+		}
+		err := k.probe(k.bus)
+		k.tLast = time.Now()
+		k.mu.Unlock()
+		if err != nil && ctx.Err() == nil && k.onFailure != nil {
+			k.onFailure(err)
+		}
+		t.Reset(k.interval)
+	}
+}
@@ -0,0 +1,82 @@
+package modbus_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/knieriem/modbus"
+	"github.com/knieriem/modbus/internal/mocknet"
+)
+
+func TestKeepAlive(t *testing.T) {
+	var alive, probes int32 = 1, 0
+	nc := mocknet.New(func(_ context.Context, req []byte, _ *modbus.ExpectedRespLenSpec) ([]byte, error) {
+		if atomic.LoadInt32(&alive) == 0 {
+			return nil, nil
+		}
+		return req[:2], nil
+	})
+	netw := modbus.NewNetwork(nc)
+	probe := func(bus modbus.Bus) error {
+		atomic.AddInt32(&probes, 1)
+		return bus.Request(1, 0x41, nil, nil)
+	}
+	failed := make(chan error, 1)
+	k := modbus.NewKeepAlive(netw, 20*time.Millisecond, probe, func(err error) {
+		select {
+		case failed <- err:
+		default:
+		}
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go k.Run(ctx)
+
+	// application requests defer the probes
+	for i := 0; i < 10; i++ {
+		err := k.Request(1, 0x41, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if n := atomic.LoadInt32(&probes); n != 0 {
+		t.Errorf("%d probes issued on a busy connection", n)
+	}
+
+	atomic.StoreInt32(&alive, 0)
+	select {
+	case err := <-failed:
+		if err != modbus.ErrTimeout {
+			t.Errorf("got %v, want ErrTimeout", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("failure callback not called")
+	}
+}
+
+func TestKeepAliveNilCallback(t *testing.T) {
+	nc := mocknet.New(func(context.Context, []byte, *modbus.ExpectedRespLenSpec) ([]byte, error) {
+		return nil, nil
+	})
+	probed := make(chan struct{}, 1)
+	k := modbus.NewKeepAlive(modbus.NewNetwork(nc), time.Millisecond, func(bus modbus.Bus) error {
+		select {
+		case probed <- struct{}{}:
+		default:
+		}
+		return bus.Request(1, 0x41, nil, nil)
+	}, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		k.Run(ctx)
+		close(done)
+	}()
+	<-probed
+	<-probed
+	cancel()
+	<-done
+}
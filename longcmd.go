@@ -0,0 +1,70 @@
+package modbus
+
+// A CommEventCount is the result of a Get Comm Event Counter request.
+type CommEventCount struct {
+	// Busy reports whether the device is still processing
+	// a previously issued program command.
+	Busy bool
+
+	// Count is incremented by the device for each
+	// successfully completed message.
+	Count uint16
+}
+
+func (c *CommEventCount) Decode(buf []byte) error {
+	if len(buf) != 4 {
+		return NewInvalidLen(MsgContextData, len(buf), 4)
+	}
+	switch ByteOrder.Uint16(buf) {
+	case 0:
+		c.Busy = false
+	case 0xFFFF:
+		c.Busy = true
+	default:
+		return Error("invalid comm event counter status")
+	}
+	c.Count = ByteOrder.Uint16(buf[2:])
+	return nil
+}
+
+// GetCommEventCount issues a Get Comm Event Counter (0x0B) request.
+func GetCommEventCount(d Device, opts ...ReqOption) (*CommEventCount, error) {
+	c := new(CommEventCount)
+	opts = append(opts, ExpectedRespLen(1+4))
+	err := d.Request(uint8(FnGetCommEventCount), nil, c, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// A PendingCommand refers to a command that a device
+// has acknowledged, but is still processing.
+type PendingCommand struct {
+	dev  Device
+	opts []ReqOption
+}
+
+// LongCommand issues a request that may take the device a long
+// time to process. If the device responds with the exception XACK,
+// a PendingCommand is returned, which can be polled until the
+// device has finished processing the command. If the device responds
+// normally, the command has completed, and a nil PendingCommand
+// is returned. The request options are also used when polling.
+func LongCommand(d Device, fn uint8, req Request, resp Response, opts ...ReqOption) (*PendingCommand, error) {
+	err := d.Request(fn, req, resp, opts...)
+	if err == XACK {
+		return &PendingCommand{dev: d, opts: opts}, nil
+	}
+	return nil, err
+}
+
+// Poll reads the comm event counter of the device, and
+// reports whether the device has finished processing the command.
+func (c *PendingCommand) Poll() (done bool, err error) {
+	st, err := GetCommEventCount(c.dev, c.opts...)
+	if err != nil {
+		return false, err
+	}
+	return !st.Busy, nil
+}
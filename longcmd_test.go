@@ -0,0 +1,102 @@
+package modbus_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/knieriem/modbus"
+	"github.com/knieriem/modbus/internal/mocknet"
+)
+
+// A programDevice acknowledges program commands, and then
+// reports being busy for the specified number of polls.
+type programDevice struct {
+	busyPolls int
+	ack       bool
+}
+
+func (d *programDevice) handle(_ context.Context, req []byte, _ *modbus.ExpectedRespLenSpec) ([]byte, error) {
+	switch modbus.FuncCode(req[1]) {
+	case 0x41:
+		if d.ack {
+			return []byte{req[0], req[1] | 0x80, byte(modbus.XACK)}, nil
+		}
+		return req[:2], nil
+	case modbus.FnGetCommEventCount:
+		resp := []byte{req[0], req[1], 0, 0, 0, 1}
+		if d.busyPolls > 0 {
+			d.busyPolls--
+			resp[2], resp[3] = 0xFF, 0xFF
+		}
+		return resp, nil
+	}
+	return []byte{req[0], req[1] | 0x80, byte(modbus.XIllegalFunc)}, nil
+}
+
+func TestLongCommand(t *testing.T) {
+	pd := &programDevice{ack: true, busyPolls: 3}
+	d := mocknet.Device(modbus.NewNetwork(mocknet.New(pd.handle)), 1)
+
+	pc, err := modbus.LongCommand(d, 0x41, rawData{1}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pc == nil {
+		t.Fatal("no pending command returned on XACK")
+	}
+	nPolls := 0
+	for {
+		done, err := pc.Poll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		nPolls++
+		if done {
+			break
+		}
+		if nPolls > 10 {
+			t.Fatal("device still busy")
+		}
+	}
+	if nPolls != 4 {
+		t.Errorf("done after %d polls, want 4", nPolls)
+	}
+
+	// An expected length function for the command
+	// must not affect the polling requests.
+	pd.busyPolls = 1
+	lenFunc := modbus.ExpectedRespLenFunc(func([]byte) int { return 2 })
+	pc, err = modbus.LongCommand(d, 0x41, rawData{1}, nil, lenFunc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, wantDone := range []bool{false, true} {
+		done, err := pc.Poll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if done != wantDone {
+			t.Errorf("Poll: got done=%v, want %v", done, wantDone)
+		}
+	}
+
+	// a command completing immediately
+	pd.ack = false
+	pc, err = modbus.LongCommand(d, 0x41, rawData{1}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pc != nil {
+		t.Error("pending command returned for a completed command")
+	}
+}
+
+func TestGetCommEventCount(t *testing.T) {
+	d := mocknet.Device(modbus.NewNetwork(mocknet.New(func(_ context.Context, req []byte, _ *modbus.ExpectedRespLenSpec) ([]byte, error) {
+		return []byte{req[0], req[1], 0x12, 0x34, 0, 1}, nil
+	})), 1)
+	_, err := modbus.GetCommEventCount(d)
+	if err == nil {
+		t.Error("invalid status accepted")
+	}
+}
@@ -46,7 +46,7 @@ func (m *msg) Decode(buf []byte) (err error) {
 }
 
 func (t *Transport) Request(req []byte, opts ...modbus.ReqOption) (resp []byte, err error) {
-	err = t.dev.Request(0x2B, &msg{typ: t.typ, data: req}, &t.respBuf, opts...)
+	err = t.dev.Request(uint8(modbus.FnMEI), &msg{typ: t.typ, data: req}, &t.respBuf, opts...)
 	if err != nil {
 		return
 	}
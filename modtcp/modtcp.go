@@ -43,8 +43,36 @@ type Conn struct {
 
 	readMgr *serframe.Stream
 	ExitC   <-chan error
+	exitC   chan error
 
 	OnReceiveError func(*Conn, error)
+
+	// ExpectEcho tells that the request is expected to be echoed
+	// before the response, as done by some gateways to
+	// a serial line that leak the local echo of the serial interface.
+	// The echo is verified and skipped.
+	ExpectEcho bool
+
+	sendInterceptor func([]byte) []byte
+	sent            []byte
+
+	lastHdr Header
+}
+
+// Header contains the fields of an MBAP header.
+type Header struct {
+	TransactionID uint16
+	ProtocolID    uint16
+	Length        uint16
+	Unit          uint8
+}
+
+// LastHeader returns the MBAP header of the most recently
+// received frame, regardless of whether the frame has been accepted
+// as a valid response. This helps to diagnose e.g. transaction ID
+// mismatches or inconsistent length fields.
+func (m *Conn) LastHeader() Header {
+	return m.lastHdr
 }
 
 func NewNetConn(conn net.Conn) (m *Conn) {
@@ -52,7 +80,8 @@ func NewNetConn(conn net.Conn) (m *Conn) {
 	m.conn = conn
 
 	m.buf.w = new(bytes.Buffer)
-	m.buf.r = make([]byte, aduSizeMax)
+	// reserve space for an optional echoed request frame
+	m.buf.r = make([]byte, 2*aduSizeMax)
 
 	m.readMgr = serframe.NewStream(conn,
 		serframe.WithReceptionOptions(
@@ -72,10 +101,39 @@ func NewNetConn(conn net.Conn) (m *Conn) {
 	return
 }
 
+// NewMessageConn returns a Conn for a message oriented net.Conn,
+// like a unix datagram socket, where each Read returns exactly one
+// complete ADU. Framing based on timeouts is not performed;
+// the MBAP length field is validated against the message size.
+func NewMessageConn(conn net.Conn) (m *Conn) {
+	m = new(Conn)
+	m.conn = conn
+
+	m.buf.w = new(bytes.Buffer)
+	m.buf.r = make([]byte, aduSizeMax)
+
+	m.exitC = make(chan error, 1)
+	m.ExitC = m.exitC
+	return
+}
+
 func (m *Conn) Name() string {
 	return "tcp"
 }
 
+// MaxPDULen returns the maximum PDU length of a Modbus TCP ADU,
+// which equals the limit of the serial line variant.
+func (m *Conn) MaxPDULen() int {
+	return pduSize
+}
+
+// NeedsTurnaroundDelay reports false, since a Modbus TCP
+// server, or gateway, is able to accept the next request
+// immediately after a broadcast.
+func (m *Conn) NeedsTurnaroundDelay() bool {
+	return false
+}
+
 func (m *Conn) Device() interface{} {
 	return m.conn
 }
@@ -96,17 +154,35 @@ func (m *Conn) Send() (adu modbus.ADU, err error) {
 
 	adu.PDUStart = mbapHdrSize
 	adu.Bytes = buf
-	err = m.readMgr.StartReception(m.buf.r)
+	if f := m.sendInterceptor; f != nil {
+		adu.Bytes = f(append([]byte(nil), buf...))
+	}
+	m.sent = adu.Bytes
+	if m.readMgr == nil {
+		_, err = m.conn.Write(adu.Bytes)
+		return adu, err
+	}
+	var opts []serframe.ReceptionOption
+	if m.ExpectEcho {
+		opts = append(opts, serframe.WithLocalEcho(adu.Bytes))
+	}
+	err = m.readMgr.StartReception(m.buf.r, opts...)
 	if err != nil {
 		return adu, err
 	}
-	_, err = b.WriteTo(m.conn)
+	_, err = m.conn.Write(adu.Bytes)
 	if err != nil {
 		m.readMgr.CancelReception()
 	}
 	return adu, err
 }
 
+// SetSendInterceptor installs a function that may modify
+// a copy of each ADU before it is sent; see modbus.Network.SendInterceptor.
+func (m *Conn) SetSendInterceptor(f func([]byte) []byte) {
+	m.sendInterceptor = f
+}
+
 func (m *Conn) Receive(ctx context.Context, tMax time.Duration, ls *modbus.ExpectedRespLenSpec) (adu modbus.ADU, err error) {
 	if f := m.OnReceiveError; f != nil {
 		defer func() {
@@ -118,16 +194,20 @@ func (m *Conn) Receive(ctx context.Context, tMax time.Duration, ls *modbus.Expec
 
 retry:
 	adu.PDUStart = mbapHdrSize
-	adu.Bytes, err = m.readMgr.ReadFrame(ctx,
-		serframe.WithInitialTimeout(tMax),
-		serframe.WithInterByteTimeout(tMax),
-	)
+	adu.Bytes, err = m.readFrame(ctx, tMax)
 	if err != nil {
-		err = rtu.ConvertSerframeError(err)
 		return
 	}
 	buf := adu.Bytes
 	n := len(buf)
+	if n >= mbapHdrSize {
+		m.lastHdr = Header{
+			TransactionID: bo.Uint16(buf[hdrPosTxnID:]),
+			ProtocolID:    bo.Uint16(buf[hdrPosProtoID:]),
+			Length:        bo.Uint16(buf[hdrPosLen:]),
+			Unit:          buf[hdrPosUnit],
+		}
+	}
 	if n < mbapHdrSize+1 {
 		err = modbus.NewInvalidLen(modbus.MsgContextADU, n, mbapHdrSize+1)
 		return
@@ -148,9 +228,11 @@ retry:
 	tID := bo.Uint16(buf[hdrPosTxnID:])
 	switch {
 	case tID < m.transactionID:
-		err = m.readMgr.StartReception(m.buf.r)
-		if err != nil {
-			return
+		if m.readMgr != nil {
+			err = m.readMgr.StartReception(m.buf.r)
+			if err != nil {
+				return
+			}
 		}
 		goto retry
 	case tID != m.transactionID:
@@ -159,3 +241,57 @@ retry:
 	}
 	return
 }
+
+func (m *Conn) readFrame(ctx context.Context, tMax time.Duration) (buf []byte, err error) {
+	if m.readMgr == nil {
+		return m.readMsg(ctx, tMax)
+	}
+	buf, err = m.readMgr.ReadFrame(ctx,
+		serframe.WithInitialTimeout(tMax),
+		serframe.WithInterByteTimeout(tMax),
+	)
+	if err != nil {
+		err = rtu.ConvertSerframeError(err)
+	}
+	return
+}
+
+func (m *Conn) readMsg(ctx context.Context, tMax time.Duration) ([]byte, error) {
+	m.conn.SetReadDeadline(time.Now().Add(tMax))
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			m.conn.SetReadDeadline(time.Now())
+		case <-done:
+		}
+	}()
+	skipEcho := m.ExpectEcho
+readMsg:
+	n, err := m.conn.Read(m.buf.r)
+	if err == nil && skipEcho {
+		skipEcho = false
+		if !bytes.Equal(m.buf.r[:n], m.sent) {
+			return nil, modbus.ErrEchoMismatch
+		}
+		goto readMsg
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		var ne net.Error
+		if errors.As(err, &ne) && ne.Timeout() {
+			return nil, modbus.ErrTimeout
+		}
+		if errors.Is(err, io.EOF) {
+			select {
+			case m.exitC <- err:
+			default:
+			}
+		}
+		return nil, err
+	}
+	return m.buf.r[:n], nil
+}
@@ -0,0 +1,267 @@
+package modtcp
+
+import (
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/knieriem/modbus"
+	"github.com/knieriem/modbus/internal/mocknet"
+	"github.com/knieriem/modbus/register"
+	"github.com/knieriem/modbus/server"
+)
+
+func startServer(t *testing.T, srv *Server) net.Conn {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Serve(l)
+	t.Cleanup(func() { l.Close() })
+	c, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+// TestReadReturnsEarly verifies that a read request returns as soon
+// as the complete response has been received, instead of waiting
+// for the response timeout.
+func TestReadReturnsEarly(t *testing.T) {
+	store := server.NewMapStore()
+	for i := uint16(0); i < 8; i++ {
+		store.Input[i] = 100 + i
+	}
+	c := startServer(t, &Server{Bus: server.NewHandler(store)})
+
+	const timeout = 2 * time.Second
+	netw := modbus.NewNetwork(NewNetConn(c))
+	netw.ResponseTimeout = timeout
+	d := register.NewDevice(mocknet.Device(netw, 1))
+
+	for n := 1; n <= 8; n++ {
+		regs := make([]uint16, n)
+		t0 := time.Now()
+		err := d.ReadInputRegs(0, regs)
+		elapsed := time.Since(t0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if elapsed > timeout/4 {
+			t.Errorf("reading %d registers took %v", n, elapsed)
+		}
+		for i, v := range regs {
+			if v != 100+uint16(i) {
+				t.Errorf("register %d: got %d", i, v)
+			}
+		}
+	}
+}
+
+type rawReq []byte
+
+func (r rawReq) Encode(w io.Writer) error {
+	_, err := w.Write(r)
+	return err
+}
+
+func TestMaxPDULen(t *testing.T) {
+	c1, c2 := net.Pipe()
+	defer c1.Close()
+	defer c2.Close()
+	received := make(chan int, 1)
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, err := c2.Read(buf)
+			if err != nil {
+				return
+			}
+			received <- n
+		}
+	}()
+	netw := modbus.NewNetwork(NewMessageConn(c1))
+	if n := netw.MaxRequestLen(); n != 253 {
+		t.Errorf("MaxRequestLen: got %d, want 253", n)
+	}
+	if n := netw.MaxResponseLen(); n != 253 {
+		t.Errorf("MaxResponseLen: got %d, want 253", n)
+	}
+
+	// A broadcast does not wait for a response.
+	err := netw.Request(0, 0x41, make(rawReq, 252), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := <-received; n != 7+253 {
+		t.Errorf("sent %d bytes, want %d", n, 7+253)
+	}
+	err = netw.Request(0, 0x41, make(rawReq, 253), nil)
+	if err != modbus.ErrMaxReqLenExceeded {
+		t.Errorf("got %v, want ErrMaxReqLenExceeded", err)
+	}
+}
+
+// serveMsgs answers each message read from c with the messages
+// returned by respond, each of them written separately.
+func serveMsgs(c net.Conn, respond func(req []byte) [][]byte) {
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, err := c.Read(buf)
+			if err != nil {
+				return
+			}
+			for _, msg := range respond(append([]byte(nil), buf[:n]...)) {
+				_, err = c.Write(msg)
+				if err != nil {
+					return
+				}
+			}
+		}
+	}()
+}
+
+// respMsg returns a response ADU to req, containing pdu.
+func respMsg(req []byte, pdu ...byte) []byte {
+	b := append([]byte(nil), req[:mbapHdrSize]...)
+	bo.PutUint16(b[hdrPosLen:], uint16(1+len(pdu)))
+	return append(b, pdu...)
+}
+
+func TestMessageConn(t *testing.T) {
+	c1, c2 := net.Pipe()
+	defer c1.Close()
+	defer c2.Close()
+	serveMsgs(c2, func(req []byte) [][]byte {
+		if req[hdrPosPDU+2] == 0xFF {
+			// length field inconsistent with the message size
+			resp := respMsg(req, 3, 2, 0, 1)
+			resp[hdrPosLen+1]++
+			return [][]byte{resp}
+		}
+		return [][]byte{respMsg(req, 3, 2, 0, req[hdrPosPDU+2])}
+	})
+	netw := modbus.NewNetwork(NewMessageConn(c1))
+	netw.ResponseTimeout = time.Second
+	d := register.NewDevice(mocknet.Device(netw, 1))
+
+	for _, addr := range []uint16{5, 6} {
+		var v uint16
+		err := d.ReadHoldingRegs(addr, &v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v != addr {
+			t.Errorf("got %d, want %d", v, addr)
+		}
+	}
+
+	var v uint16
+	err := d.ReadHoldingRegs(0xFF, &v)
+	var il *modbus.InvalidLenError
+	if !errors.As(err, &il) {
+		t.Errorf("got %v, want an InvalidLenError", err)
+	}
+}
+
+func TestExpectEcho(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		newConn func(net.Conn) *Conn
+	}{
+		{"stream", NewNetConn},
+		{"message", NewMessageConn},
+	} {
+		c1, c2 := net.Pipe()
+		serveMsgs(c2, func(req []byte) [][]byte {
+			echo := append([]byte(nil), req...)
+			if req[hdrPosPDU+2] == 0xFF {
+				echo[hdrPosPDU+4]++
+			}
+			return [][]byte{echo, respMsg(req, 3, 2, 0, req[hdrPosPDU+2])}
+		})
+		m := tc.newConn(c1)
+		m.ExpectEcho = true
+		netw := modbus.NewNetwork(m)
+		netw.ResponseTimeout = time.Second
+		d := register.NewDevice(mocknet.Device(netw, 1))
+
+		var v uint16
+		err := d.ReadHoldingRegs(5, &v)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if v != 5 {
+			t.Errorf("%s: got %d, want 5", tc.name, v)
+		}
+		err = d.ReadHoldingRegs(0xFF, &v)
+		if err != modbus.ErrEchoMismatch {
+			t.Errorf("%s: got %v, want ErrEchoMismatch", tc.name, err)
+		}
+		c1.Close()
+		c2.Close()
+	}
+}
+
+func TestLastHeader(t *testing.T) {
+	c1, c2 := net.Pipe()
+	defer c1.Close()
+	defer c2.Close()
+	serveMsgs(c2, func(req []byte) [][]byte {
+		resp := respMsg(req, 3, 2, 0, 1)
+		if req[hdrPosPDU+2] == 0xFF {
+			bo.PutUint16(resp[hdrPosTxnID:], 0x1234)
+		}
+		return [][]byte{resp}
+	})
+	m := NewMessageConn(c1)
+	netw := modbus.NewNetwork(m)
+	netw.ResponseTimeout = time.Second
+	d := register.NewDevice(mocknet.Device(netw, 7))
+
+	var v uint16
+	for i := 1; i <= 2; i++ {
+		err := d.ReadHoldingRegs(0, &v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := Header{TransactionID: uint16(i), Length: 5, Unit: 7}
+		if h := m.LastHeader(); h != want {
+			t.Errorf("request %d: got %+v, want %+v", i, h, want)
+		}
+	}
+
+	// the header of a rejected response is available too
+	err := d.ReadHoldingRegs(0xFF, &v)
+	if err != ErrTransactionIDMismatch {
+		t.Fatalf("got %v, want ErrTransactionIDMismatch", err)
+	}
+	if h := m.LastHeader(); h.TransactionID != 0x1234 || h.Unit != 7 {
+		t.Errorf("got %+v", h)
+	}
+}
+
+func TestBroadcastNoTurnaroundDelay(t *testing.T) {
+	const delay = 500 * time.Millisecond
+	c1, c2 := net.Pipe()
+	defer c1.Close()
+	defer c2.Close()
+	serveMsgs(c2, func([]byte) [][]byte { return nil })
+	netw := modbus.NewNetwork(NewMessageConn(c1))
+	netw.TurnaroundDelay = delay
+
+	t0 := time.Now()
+	err := register.NewDevice(mocknet.Device(netw, 0)).WriteReg(1, uint16(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(t0); d >= delay/2 {
+		t.Errorf("broadcast took %v", d)
+	}
+}
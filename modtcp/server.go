@@ -8,6 +8,7 @@ package modtcp
 
 import (
 	"bufio"
+	"context"
 	"errors"
 	"io"
 	"net"
@@ -28,6 +29,30 @@ type Server struct {
 		SendException bool
 	}
 
+	// BusyRetries specifies how many times a request is repeated
+	// after Bus returned XDeviceBusy or XACK, waiting BusyRetryDelay
+	// before each retry. If BusyRetryBudget is not zero, no retry
+	// is started that would end the delay later than BusyRetryBudget
+	// after the first attempt has been started.
+	BusyRetries     int
+	BusyRetryDelay  time.Duration
+	BusyRetryBudget time.Duration
+
+	// UnitMapper optionally maps the unit identifier of a request
+	// to the device address used when forwarding the request to Bus,
+	// e.g. in case of a gateway to a serial line.
+	// The response will contain the original unit identifier.
+	UnitMapper func(unit uint8) uint8
+
+	// CancelOnDisconnect makes the server watch a client connection
+	// while forwarding a request to Bus. If the client disconnects,
+	// the context passed to Bus using modbus.WithContext is cancelled,
+	// so that the Bus is freed early. Since end of input is
+	// treated as a disconnect, a client that half-closes its side
+	// of the connection after sending a request will not
+	// receive a response if this option is enabled.
+	CancelOnDisconnect bool
+
 	// ConnState specifies an optional callback function that is
 	// called when a client connection changes state. See the
 	// ConnState type and associated constants for details.
@@ -100,6 +125,39 @@ func (c *conn) readFull(b []byte) error {
 	return err
 }
 
+// watchDisconnect returns a context that is cancelled if the
+// client closes the connection while a request is being forwarded
+// to the Bus. The returned function stops watching, and reports
+// whether the client has disconnected; it must be called before
+// reading from the connection again.
+func (c *conn) watchDisconnect() (context.Context, func() bool) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	// clear a deadline possibly set by readFull
+	c.SetReadDeadline(time.Time{})
+	go func() {
+		defer close(done)
+		_, err := c.rb.Peek(1)
+		if err == nil {
+			return // data of a subsequent request
+		}
+		var ne net.Error
+		if errors.As(err, &ne) && ne.Timeout() {
+			return
+		}
+		cancel()
+	}()
+	return ctx, func() bool {
+		c.SetReadDeadline(time.Now())
+		<-done
+		c.SetReadDeadline(time.Time{})
+		gone := ctx.Err() != nil
+		cancel()
+		return gone
+	}
+}
+
 func (c *conn) setState(state ConnState) {
 	if hook := c.server.ConnState; hook != nil {
 		hook(c.Conn, ConnState(state))
@@ -143,8 +201,38 @@ func (srv *Server) handleConn(c *conn) error {
 		}
 
 		fn := pdu[0]
-		resp := resp[:mbapHdrSize]
-		err = srv.Bus.Request(unit, fn, rawData(pdu[1:]), &resp)
+		busAddr := unit
+		if m := srv.UnitMapper; m != nil {
+			busAddr = m(unit)
+		}
+		resp := resp
+		ctx, stopWatching := context.Background(), func() bool { return false }
+		if srv.CancelOnDisconnect {
+			ctx, stopWatching = c.watchDisconnect()
+		}
+		t0 := time.Now()
+		for i := 0; ; i++ {
+			resp = resp[:mbapHdrSize]
+			err = srv.Bus.Request(busAddr, fn, rawData(pdu[1:]), &resp, modbus.WithContext(ctx))
+			if i == srv.BusyRetries || !isBusy(err) {
+				break
+			}
+			d := srv.BusyRetryDelay
+			if b := srv.BusyRetryBudget; b != 0 && time.Since(t0)+d > b {
+				break
+			}
+			select {
+			case <-time.After(d):
+			case <-ctx.Done():
+			}
+			if ctx.Err() != nil {
+				err = ctx.Err()
+				break
+			}
+		}
+		if stopWatching() {
+			return errors.New("modtcp: client disconnected during request")
+		}
 		resp[hdrPosUnit] = unit
 		if err != nil {
 			switch e := err.(type) {
@@ -177,6 +265,10 @@ func (srv *Server) handleConn(c *conn) error {
 	}
 }
 
+func isBusy(err error) bool {
+	return err == modbus.XDeviceBusy || err == modbus.XACK
+}
+
 type rawData []byte
 
 func (b *rawData) Decode(buf []byte) (err error) {
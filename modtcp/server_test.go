@@ -0,0 +1,228 @@
+package modtcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/knieriem/modbus"
+	"github.com/knieriem/modbus/internal/mocknet"
+	"github.com/knieriem/modbus/register"
+	"github.com/knieriem/modbus/server"
+)
+
+// readHoldingReq is a Modbus/TCP read holding registers request
+// for one register at address 0, unit 1.
+var readHoldingReq = []byte{0, 1, 0, 0, 0, 6, 1, 3, 0, 0, 0, 1}
+
+// blockingBus returns a Bus that blocks each request until its
+// context is cancelled; cancellations are reported on the channel.
+func blockingBus() (modbus.Bus, <-chan struct{}) {
+	cancelled := make(chan struct{}, 1)
+	nc := mocknet.New(func(ctx context.Context, _ []byte, _ *modbus.ExpectedRespLenSpec) ([]byte, error) {
+		select {
+		case <-ctx.Done():
+			cancelled <- struct{}{}
+			return nil, ctx.Err()
+		case <-time.After(5 * time.Second):
+			return nil, modbus.ErrTimeout
+		}
+	})
+	netw := modbus.NewNetwork(nc)
+	netw.ResponseTimeout = 5 * time.Second
+	return netw, cancelled
+}
+
+func serveConn(srv *Server, nc net.Conn) <-chan error {
+	done := make(chan error, 1)
+	go func() {
+		done <- srv.handleConn(&conn{Conn: nc, rb: bufio.NewReader(nc), server: srv})
+	}()
+	return done
+}
+
+func TestCancelOnDisconnect(t *testing.T) {
+	bus, cancelled := blockingBus()
+	srv := &Server{Bus: bus, CancelOnDisconnect: true, ReadTimeout: 10 * time.Millisecond}
+	client, srvConn := net.Pipe()
+	done := serveConn(srv, srvConn)
+
+	_, err := client.Write(readHoldingReq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// let the request outlast the ReadTimeout
+	time.Sleep(50 * time.Millisecond)
+	client.Close()
+
+	select {
+	case <-cancelled:
+	case <-time.After(2 * time.Second):
+		t.Fatal("backend request not cancelled")
+	}
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("handleConn returned without error")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("handleConn did not return")
+	}
+}
+
+func TestNoCancelByDefault(t *testing.T) {
+	bus, cancelled := blockingBus()
+	srv := &Server{Bus: bus}
+	client, srvConn := net.Pipe()
+	serveConn(srv, srvConn)
+
+	_, err := client.Write(readHoldingReq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Close()
+
+	select {
+	case <-cancelled:
+		t.Fatal("backend request cancelled, although CancelOnDisconnect is not set")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestCancelOnDisconnectKeepsServing(t *testing.T) {
+	store := server.NewMapStore()
+	store.Holding[0] = 42
+	c := startServer(t, &Server{Bus: server.NewHandler(store), CancelOnDisconnect: true})
+	d := register.NewDevice(mocknet.Device(modbus.NewNetwork(NewNetConn(c)), 1))
+
+	for i := 0; i < 3; i++ {
+		var v uint16
+		err := d.ReadHoldingRegs(0, &v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v != 42 {
+			t.Errorf("got %d", v)
+		}
+	}
+}
+
+type busFunc func(addr, fn uint8, req modbus.Request, resp modbus.Response) error
+
+func (f busFunc) Request(addr, fn uint8, req modbus.Request, resp modbus.Response, _ ...modbus.ReqOption) error {
+	return f(addr, fn, req, resp)
+}
+
+// busyBus returns a Bus answering read holding registers requests
+// with XDeviceBusy nBusy times, and then with a register
+// value of 42. The number of requests is counted in *n.
+func busyBus(nBusy int, n *int) modbus.Bus {
+	return busFunc(func(_, _ uint8, _ modbus.Request, resp modbus.Response) error {
+		*n++
+		if *n <= nBusy {
+			return modbus.XDeviceBusy
+		}
+		return resp.Decode([]byte{2, 0, 42})
+	})
+}
+
+// exchange sends req to the server, and returns the response.
+func exchange(t *testing.T, client net.Conn, req []byte) []byte {
+	t.Helper()
+	_, err := client.Write(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetReadDeadline(time.Now().Add(2 * time.Second))
+	hdr := make([]byte, mbapHdrSize)
+	_, err = io.ReadFull(client, hdr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pdu := make([]byte, bo.Uint16(hdr[hdrPosLen:])-1)
+	_, err = io.ReadFull(client, pdu)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return append(hdr, pdu...)
+}
+
+func TestBusyRetries(t *testing.T) {
+	n := 0
+	srv := &Server{Bus: busyBus(2, &n), BusyRetries: 3, BusyRetryDelay: 10 * time.Millisecond}
+	client, srvConn := net.Pipe()
+	defer client.Close()
+	serveConn(srv, srvConn)
+
+	resp := exchange(t, client, readHoldingReq)
+	if want := []byte{0, 1, 0, 0, 0, 5, 1, 3, 2, 0, 42}; !bytes.Equal(resp, want) {
+		t.Errorf("got response % x, want % x", resp, want)
+	}
+	if n != 3 {
+		t.Errorf("%d bus requests, want 3", n)
+	}
+}
+
+func TestBusyRetryBudget(t *testing.T) {
+	n := 0
+	srv := &Server{
+		Bus:             busyBus(2, &n),
+		BusyRetries:     3,
+		BusyRetryDelay:  20 * time.Millisecond,
+		BusyRetryBudget: 30 * time.Millisecond,
+		WriteTimeout:    time.Second,
+	}
+	client, srvConn := net.Pipe()
+	defer client.Close()
+	serveConn(srv, srvConn)
+
+	resp := exchange(t, client, readHoldingReq)
+	if want := []byte{0, 1, 0, 0, 0, 3, 1, 0x83, byte(modbus.XDeviceBusy)}; !bytes.Equal(resp, want) {
+		t.Errorf("got response % x, want % x", resp, want)
+	}
+	if n != 2 {
+		t.Errorf("%d bus requests, want 2", n)
+	}
+}
+
+func TestUnitMapper(t *testing.T) {
+	var busAddr uint8
+	bus := busFunc(func(addr, _ uint8, _ modbus.Request, resp modbus.Response) error {
+		busAddr = addr
+		return resp.Decode([]byte{2, 0, addr})
+	})
+	srv := &Server{Bus: bus, UnitMapper: func(unit uint8) uint8 {
+		switch unit {
+		case 5:
+			return 1
+		case 1:
+			return 5
+		}
+		return unit
+	}}
+	client, srvConn := net.Pipe()
+	defer client.Close()
+	serveConn(srv, srvConn)
+
+	for _, tc := range []struct {
+		unit, addr uint8
+	}{
+		{5, 1},
+		{1, 5},
+		{7, 7},
+	} {
+		req := append([]byte(nil), readHoldingReq...)
+		req[hdrPosUnit] = tc.unit
+		resp := exchange(t, client, req)
+		if busAddr != tc.addr {
+			t.Errorf("unit %d: forwarded to %d, want %d", tc.unit, busAddr, tc.addr)
+		}
+		if want := []byte{0, 1, 0, 0, 0, 5, tc.unit, 3, 2, 0, tc.addr}; !bytes.Equal(resp, want) {
+			t.Errorf("unit %d: got response % x, want % x", tc.unit, resp, want)
+		}
+	}
+}
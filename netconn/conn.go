@@ -4,11 +4,13 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"log"
 	"net"
 	"strconv"
 	"strings"
 
 	"github.com/knieriem/modbus"
+	"github.com/knieriem/modbus/debug"
 	"github.com/knieriem/text/tidata"
 )
 
@@ -52,8 +54,13 @@ var overridableFieldsMap = map[string]int{
 }
 
 type Proto struct {
-	Name           string
-	Dial           func(*Conf) (*Conn, error)
+	Name string
+	Dial func(*Conf) (*Conn, error)
+
+	// Validate, if not nil, checks whether the interface
+	// a Conf refers to is present, without opening it.
+	Validate func(*Conf) error
+
 	RequiredFields int
 	OptionalFields int
 	InterfaceGroup *InterfaceGroup
@@ -77,6 +84,39 @@ type Conn struct {
 	Conf       *Conf
 }
 
+// Network returns a new modbus.Network using the connection,
+// with tracef, which may be nil, as its trace function. When the
+// connection terminates, a request in progress is aborted, and the
+// error is forwarded to the returned channel, which is closed afterwards.
+// Since the returned channel is fed from c.ExitC, the caller should
+// not receive from c.ExitC directly. If c.ExitC is nil,
+// the returned channel is nil too.
+func (c *Conn) Network(tracef modbus.TraceFunc) (netw *modbus.Network, exitC <-chan error) {
+	netw = modbus.NewNetwork(c.NetConn)
+	netw.Tracef = tracef
+	if c.ExitC == nil {
+		return netw, nil
+	}
+	fwd := make(chan error, 1)
+	go func(exitC <-chan error) {
+		err, ok := <-exitC
+		netw.Abort()
+		if ok {
+			fwd <- err
+		}
+		close(fwd)
+	}(c.ExitC)
+	return netw, fwd
+}
+
+// LogTrace returns a trace function that prints
+// messages to l, formatted using debug.FormatMsg.
+func LogTrace(l *log.Logger) modbus.TraceFunc {
+	return func(msgDir string, adu modbus.ADU, err error, ncName string) {
+		l.Println(debug.FormatMsg(msgDir, adu, err, ncName))
+	}
+}
+
 type Conf struct {
 	tidataInfo
 
@@ -327,6 +367,43 @@ func (list ConfList) Postprocess() (err error) {
 	return
 }
 
+// Validate checks, without opening any connection, whether
+// the interface each Conf refers to exists: for protocols with
+// a device field, like serial ports, the check is performed
+// by the Validate function of the protocol, if present; for protocols
+// with an address field, the host name is resolved. The returned list
+// contains an error for each Conf, or nil, if the check succeeded.
+func (list ConfList) Validate() []error {
+	errs := make([]error, len(list))
+	for i, c := range list {
+		errs[i] = c.validate()
+	}
+	return errs
+}
+
+func (c *Conf) validate() error {
+	p, err := c.proto()
+	if err != nil {
+		return err
+	}
+	if p.Validate != nil {
+		return p.Validate(c)
+	}
+	if p.fieldFlags()&FieldAddr == 0 || c.Addr == "" {
+		return nil
+	}
+	hostport, err := c.Addr.Complete("0")
+	if err != nil {
+		return err
+	}
+	host, _, err := net.SplitHostPort(hostport)
+	if err != nil {
+		return err
+	}
+	_, err = net.LookupHost(host)
+	return err
+}
+
 func (list ConfList) Default() (index int) {
 	for i, c := range list {
 		if c.Default {
@@ -479,6 +556,42 @@ func (list ConfList) dial(connSpec, defaultProto string) (conn *Conn, err error)
 	return cf.Dial()
 }
 
+// DialFallback tries to dial the connections specified by specs in order,
+// and returns the first connection established successfully, allowing
+// to specify alternatives like a primary TCP and a fallback serial link.
+// The default protocol is "rtu", as with Dial. If all
+// alternatives fail, a *FallbackError is returned.
+func (list ConfList) DialFallback(specs ...string) (*Conn, error) {
+	fe := new(FallbackError)
+	for _, spec := range specs {
+		conn, err := list.dial(spec, "rtu")
+		if err == nil {
+			return conn, nil
+		}
+		fe.Specs = append(fe.Specs, spec)
+		fe.Errs = append(fe.Errs, err)
+	}
+	if len(fe.Errs) == 0 {
+		return nil, errors.New("no connection specified")
+	}
+	return nil, fe
+}
+
+// A FallbackError contains the errors of each
+// alternative tried by DialFallback.
+type FallbackError struct {
+	Specs []string
+	Errs  []error
+}
+
+func (e *FallbackError) Error() string {
+	s := "all connection alternatives failed"
+	for i, err := range e.Errs {
+		s += fmt.Sprintf("; %q: %v", e.Specs[i], err)
+	}
+	return s
+}
+
 func Dial(connSpec string, opts ...DialOption) (*Conn, error) {
 	var c dialConf
 	c.list = ConfList{&Conf{}}
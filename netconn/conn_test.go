@@ -0,0 +1,131 @@
+package netconn_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/knieriem/modbus"
+	"github.com/knieriem/modbus/internal/mocknet"
+	"github.com/knieriem/modbus/netconn"
+	"github.com/knieriem/modbus/register"
+)
+
+func TestConnNetwork(t *testing.T) {
+	blocked := make(chan struct{})
+	nc := mocknet.New(func(ctx context.Context, req []byte, _ *modbus.ExpectedRespLenSpec) ([]byte, error) {
+		if req[0] == 2 {
+			close(blocked)
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		return []byte{req[0], 3, 2, 0x12, 0x34}, nil
+	})
+	connExitC := make(chan error, 1)
+	c := &netconn.Conn{NetConn: nc, ExitC: connExitC}
+	var trace bytes.Buffer
+	netw, exitC := c.Network(netconn.LogTrace(log.New(&trace, "", 0)))
+	if c.ExitC != (<-chan error)(connExitC) {
+		t.Error("c.ExitC modified")
+	}
+
+	var v uint16
+	err := register.NewDevice(mocknet.Device(netw, 1)).ReadHoldingRegs(0, &v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != 0x1234 {
+		t.Errorf("got %#x, want 0x1234", v)
+	}
+	if lines := strings.Split(strings.TrimSpace(trace.String()), "\n"); len(lines) != 2 {
+		t.Errorf("unexpected trace output: %q", trace.String())
+	}
+
+	errExit := errors.New("connection lost")
+	done := make(chan error)
+	go func() {
+		done <- register.NewDevice(mocknet.Device(netw, 2)).ReadHoldingRegs(0, &v)
+	}()
+	<-blocked
+	connExitC <- errExit
+	select {
+	case err = <-done:
+		if err != context.Canceled {
+			t.Errorf("request returned %v, want context.Canceled", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("request not aborted")
+	}
+	if err := <-exitC; err != errExit {
+		t.Errorf("exit channel forwarded %v, want %v", err, errExit)
+	}
+}
+
+func TestConnNetworkNoTrace(t *testing.T) {
+	c := &netconn.Conn{NetConn: mocknet.New(nil)}
+	netw, exitC := c.Network(nil)
+	if netw.Tracef != nil {
+		t.Error("trace function set")
+	}
+	if exitC != nil {
+		t.Error("exit channel returned for a connection without ExitC")
+	}
+}
+
+func init() {
+	// a protocol for testing, the dial of which succeeds
+	// only for the device named "up"
+	netconn.RegisterProtocol(&netconn.Proto{
+		Name: "fallbacktest",
+		Dial: func(c *netconn.Conf) (*netconn.Conn, error) {
+			if c.Device != "up" {
+				return nil, errors.New("device " + c.Device + " unavailable")
+			}
+			return &netconn.Conn{NetConn: mocknet.New(nil)}, nil
+		},
+		RequiredFields: netconn.FieldDev,
+	})
+}
+
+func TestDialFallback(t *testing.T) {
+	list := netconn.ConfList{
+		{Proto: "fallbacktest", Name: "primary", Device: "down"},
+		{Proto: "fallbacktest", Name: "secondary", Device: "up"},
+		{Proto: "fallbacktest", Name: "other", Device: "off"},
+	}
+
+	c, err := list.DialFallback("primary", "secondary")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name := c.Conf.Name; name != "secondary" {
+		t.Errorf("connected to %q, want secondary", name)
+	}
+
+	_, err = list.DialFallback("primary", "other")
+	var fe *netconn.FallbackError
+	if !errors.As(err, &fe) {
+		t.Fatalf("got %v, want a FallbackError", err)
+	}
+	if len(fe.Specs) != 2 || fe.Specs[0] != "primary" || fe.Specs[1] != "other" || len(fe.Errs) != 2 {
+		t.Fatalf("got %+v", fe)
+	}
+	for i, dev := range []string{"down", "off"} {
+		want := "device " + dev + " unavailable"
+		if s := fe.Errs[i].Error(); s != want {
+			t.Errorf("error %d: got %q, want %q", i, s, want)
+		}
+		if s := fe.Error(); !strings.Contains(s, want) {
+			t.Errorf("%q does not report %q", s, want)
+		}
+	}
+
+	_, err = list.DialFallback()
+	if err == nil || errors.As(err, &fe) {
+		t.Errorf("no specs: got %v", err)
+	}
+}
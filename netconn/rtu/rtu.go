@@ -20,6 +20,7 @@ func init() {
 		Name:           "rtu",
 		OptionalFields: netconn.DevFields,
 		Dial:           dial,
+		Validate:       validate,
 		InterfaceGroup: &serialPorts,
 	})
 }
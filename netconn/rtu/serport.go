@@ -1,7 +1,9 @@
 package rtu
 
 import (
+	"errors"
 	"io"
+	"os/exec"
 	"strings"
 
 	"github.com/knieriem/modbus/netconn"
@@ -28,6 +30,36 @@ func openPort(cf *netconn.Conf) (c io.ReadWriteCloser, portName string, err erro
 	return port, portName, nil
 }
 
+// validate checks whether the serial port, or the command,
+// specified by cf.Device is present. Like dial, it uses
+// serport.Choose to select a port, but it does not
+// prompt the user.
+func validate(cf *netconn.Conf) error {
+	if c, match := parseCommand(cf.Device); match {
+		_, err := exec.LookPath(c.Args[0])
+		return err
+	}
+	switch cf.Device {
+	case "", "?", "!":
+		// The port is chosen at dial time, possibly
+		// prompting the user; at least one port must exist.
+		if len(serialPorts.Interfaces()) == 0 {
+			return errors.New("no serial port present")
+		}
+		return nil
+	}
+	name, err := serport.Choose(cf.Device)
+	if err != nil {
+		return err
+	}
+	for _, iface := range serialPorts.Interfaces() {
+		if iface.Name == name {
+			return nil
+		}
+	}
+	return errors.New("serial port not present: " + name)
+}
+
 var serialPorts = netconn.InterfaceGroup{
 	Name:       "Serial ports",
 	Interfaces: serialInterfaces,
@@ -0,0 +1,41 @@
+package rtu
+
+import (
+	"testing"
+
+	"github.com/knieriem/modbus/netconn"
+)
+
+func TestValidate(t *testing.T) {
+	enum := serialPorts.Interfaces
+	defer func() { serialPorts.Interfaces = enum }()
+	var ports []netconn.Interface
+	serialPorts.Interfaces = func() []netconn.Interface {
+		return ports
+	}
+
+	for _, tc := range []struct {
+		dev   string
+		ports []string
+		valid bool
+	}{
+		{"/dev/ttyS1", []string{"/dev/ttyS0", "/dev/ttyS1"}, true},
+		{"/dev/ttyS2", []string{"/dev/ttyS0", "/dev/ttyS1"}, false},
+		{"/dev/ttyS0", nil, false},
+		{"", []string{"/dev/ttyS0"}, true},
+		{"", nil, false},
+		{"!", nil, false},
+		{"!sh -c true", nil, true},
+		{"!nonexistent-modbus-bridge", nil, false},
+	} {
+		ports = ports[:0]
+		for _, name := range tc.ports {
+			ports = append(ports, netconn.Interface{Name: name})
+		}
+		list := netconn.ConfList{{Proto: "rtu", Device: tc.dev}}
+		err := list.Validate()[0]
+		if valid := err == nil; valid != tc.valid {
+			t.Errorf("device %q, ports %v: got %v", tc.dev, tc.ports, err)
+		}
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"io"
 	"strconv"
+	"sync"
 	"time"
 )
 
@@ -120,10 +121,46 @@ type Network struct {
 	conn NetConn
 
 	Tracef          TraceFunc
+	TraceEventf     TraceEventFunc
 	ResponseTimeout time.Duration
+
+	// TraceDecoded enables the Decoded field of TraceEvents.
+	TraceDecoded bool
+
+	// TurnaroundDelay is the time waited after a request
+	// that expects no response, like a broadcast. It is not
+	// applied if the NetConn is a TurnaroundAdvisor that
+	// reports that no delay is needed.
 	TurnaroundDelay time.Duration
 
+	// QuietBroadcastWindow, if true, makes Request listen
+	// during the turnaround delay after a broadcast, and discard
+	// frames sent erroneously by devices, so that they do not
+	// disturb the next transaction. It has an effect only if the
+	// NetConn is a FrameDiscarder, like the rtu connection.
+	// OnBroadcastResponse, if not nil, is called for each frame discarded.
+	QuietBroadcastWindow bool
+	OnBroadcastResponse  func(frame []byte)
+
+	// SendInterceptor, if not nil, is called with a copy of each ADU
+	// just before it is sent, and returns the bytes actually sent.
+	// It is a testing tool, meant for injecting faults, like flipped bits
+	// or truncated frames. It has an effect only if the NetConn
+	// implements a method SetSendInterceptor(func([]byte) []byte),
+	// like the rtu and modtcp connections do. Request passes the
+	// interceptor to the NetConn before each request, so a change
+	// takes effect with the next request.
+	// Since the ADU is complete, including e.g. a CRC, modifications
+	// behave like noise on the line: a device will usually discard
+	// the frame, so that Request returns ErrTimeout.
+	SendInterceptor func([]byte) []byte
+
 	longTurnaroundTime longTurnaroundStatus
+
+	abort struct {
+		sync.Mutex
+		cancel context.CancelFunc
+	}
 }
 
 type longTurnaroundStatus struct {
@@ -164,27 +201,88 @@ func (lt *longTurnaroundStatus) allowed(addr uint8, minElapsed time.Duration) bo
 
 type TraceFunc func(msgDir string, adu ADU, err error, netConnName string)
 
-func (t TraceFunc) withNetConnName(ncName string) TraceFunc {
-	if t == nil {
-		return nil
+// A TraceEvent describes a request or response message
+// passed to a TraceEventFunc.
+type TraceEvent struct {
+	MsgDir      string
+	ADU         ADU
+	Err         error
+	NetConnName string
+
+	// Attempt is the number of the current attempt
+	// of a request, starting at 1.
+	Attempt int
+
+	// RetryReason tells, in case Attempt is greater than one,
+	// why the request has been repeated: "timeout", "invalid reply",
+	// "exception", or "retry func".
+	RetryReason string
+
+	// Decoded contains, if Network.TraceDecoded is set, a human
+	// readable representation of a successfully decoded response,
+	// in case the Response implements fmt.Stringer.
+	Decoded string
+
+	// Labels contains the labels attached to
+	// the request using WithLabel.
+	Labels map[string]string
+}
+
+// A TraceEventFunc is, like TraceFunc, called for each message
+// sent or received, but gets a structured description of the
+// message and its context.
+type TraceEventFunc func(*TraceEvent)
+
+type tracer struct {
+	f           TraceFunc
+	evf         TraceEventFunc
+	ncName      string
+	attempt     int
+	retryReason string
+	decoded     string
+	labels      map[string]string
+}
+
+func (t *tracer) trace(msgDir string, adu ADU, err error) {
+	if t.f != nil {
+		t.f(msgDir, adu, err, t.ncName)
 	}
-	return func(msgDir string, adu ADU, err error, _ string) {
-		t(msgDir, adu, err, ncName)
+	if t.evf != nil {
+		t.evf(&TraceEvent{
+			MsgDir:      msgDir,
+			ADU:         adu,
+			Err:         err,
+			NetConnName: t.ncName,
+			Attempt:     t.attempt,
+			RetryReason: t.retryReason,
+			Decoded:     t.decoded,
+			Labels:      t.labels,
+		})
 	}
 }
 
-func (t TraceFunc) req(adu ADU, err error) {
-	if t == nil {
-		return
-	}
-	t(MsgDirReq, adu, err, "")
+func (t *tracer) req(adu ADU, err error) {
+	t.trace(MsgDirReq, adu, err)
 }
 
-func (t TraceFunc) resp(adu ADU, err error) {
-	if t == nil {
-		return
+func (t *tracer) resp(adu ADU, err error) {
+	t.trace(MsgDirResp, adu, err)
+}
+
+func (t *tracer) retry(err error) {
+	t.attempt++
+	switch {
+	case err == ErrTimeout:
+		t.retryReason = "timeout"
+	case MsgInvalid(err):
+		t.retryReason = "invalid reply"
+	default:
+		if _, ok := err.(Exception); ok {
+			t.retryReason = "exception"
+		} else {
+			t.retryReason = "retry func"
+		}
 	}
-	t(MsgDirResp, adu, err, "")
 }
 
 const (
@@ -204,6 +302,79 @@ func (netw *Network) Device() interface{} {
 	return netw.conn.Device()
 }
 
+// MaxPDULen is the maximum length of a PDU, as defined
+// by the Modbus specification.
+const MaxPDULen = 253
+
+// A PDULenLimiter is a NetConn that reports the maximum
+// PDU length supported by the transport.
+type PDULenLimiter interface {
+	MaxPDULen() int
+}
+
+func (netw *Network) maxPDULen() int {
+	if l, ok := netw.conn.(PDULenLimiter); ok {
+		return l.MaxPDULen()
+	}
+	return MaxPDULen
+}
+
+// A TurnaroundAdvisor is a NetConn that reports whether
+// a turnaround delay is required after a broadcast request.
+// This is the case for serial lines, where devices need time
+// to process the broadcast before they are able to receive the
+// next request, but not for TCP based transports.
+type TurnaroundAdvisor interface {
+	NeedsTurnaroundDelay() bool
+}
+
+// A FrameDiscarder is a NetConn able to receive and
+// discard frames for a specified duration.
+type FrameDiscarder interface {
+	DiscardFrames(ctx context.Context, d time.Duration, f func(frame []byte))
+}
+
+func (netw *Network) turnaroundDelay() time.Duration {
+	if a, ok := netw.conn.(TurnaroundAdvisor); ok && !a.NeedsTurnaroundDelay() {
+		return 0
+	}
+	return netw.TurnaroundDelay
+}
+
+// MaxRequestLen returns the maximum length of a request PDU,
+// including the function code, supported by the transport.
+// It allows callers to split large requests in advance.
+// If the NetConn is not a PDULenLimiter, MaxPDULen is returned.
+func (netw *Network) MaxRequestLen() int {
+	return netw.maxPDULen()
+}
+
+// MaxResponseLen returns the maximum length of a response PDU,
+// including the function code, supported by the transport.
+func (netw *Network) MaxResponseLen() int {
+	return netw.maxPDULen()
+}
+
+// Abort cancels the request currently in progress, if any;
+// the request will return context.Canceled.
+// While Request must not be called concurrently,
+// Abort may be called from any goroutine.
+func (netw *Network) Abort() {
+	a := &netw.abort
+	a.Lock()
+	if a.cancel != nil {
+		a.cancel()
+	}
+	a.Unlock()
+}
+
+func (netw *Network) setAbortFunc(cancel context.CancelFunc) {
+	a := &netw.abort
+	a.Lock()
+	a.cancel = cancel
+	a.Unlock()
+}
+
 type Error string
 
 func (e Error) Error() string {
@@ -271,7 +442,25 @@ func NewInvalidLen(ctx MsgContext, have int, want ...int) error {
 }
 
 func NewLengthFieldMismatch(lengthField int, dataLen int) error {
-	return fmt.Errorf("modbus: length field value (%d) and actual data length inconsistent (%d)", lengthField, dataLen)
+	return &LengthFieldMismatchError{Field: lengthField, ExpectedLen: lengthField, DataLen: dataLen}
+}
+
+// A LengthFieldMismatchError reports that the value of a length
+// field contained in a response does not match the length
+// of the data following it. ExpectedLen is the data length in bytes
+// derived from the field; it differs from Field if the field
+// does not contain a byte count, but e.g. a register count.
+type LengthFieldMismatchError struct {
+	Field       int
+	ExpectedLen int
+	DataLen     int
+}
+
+func (e *LengthFieldMismatchError) Error() string {
+	if e.ExpectedLen != e.Field {
+		return fmt.Sprintf("modbus: length field value (%d, i.e. %d bytes) and actual data length inconsistent (%d)", e.Field, e.ExpectedLen, e.DataLen)
+	}
+	return fmt.Sprintf("modbus: length field value (%d) and actual data length inconsistent (%d)", e.Field, e.DataLen)
 }
 
 func NewInvalidUserBufLen(have int, want int) error {
@@ -314,8 +503,15 @@ type reqOptions struct {
 	nRetriesOnInvalidReply int
 	retryDelay             time.Duration
 	retryFunc              RetryFunc
+	noRetry                bool
+	preSendDelay           time.Duration
+	labels                 map[string]string
 	expectedLenSpec        *ExpectedRespLenSpec
+	expectedLenFunc        func(reqData []byte) int
+	noResponse             bool
+	minFrameLen            int
 	tracef                 TraceFunc
+	traceEventf            TraceEventFunc
 	longTurnaroundTime     struct {
 		minElapsedSincePrev time.Duration
 		minDuration         time.Duration
@@ -334,12 +530,39 @@ func WithContext(ctx context.Context) ReqOption {
 func ExpectedRespLen(n int) ReqOption {
 	return func(r *reqOptions) {
 		r.expectedLenSpec = &ExpectedRespLenSpec{ValidLen: []int{n}}
+		r.expectedLenFunc = nil
+	}
+}
+
+// ExpectedRespLenFunc is a request option like ExpectedRespLen,
+// but the expected PDU size is computed by f from the data part of
+// the encoded request, i.e. the request PDU without the function code.
+// This is useful if the response length depends on request parameters,
+// like the number of registers to be read. If f returns
+// a value <= 0, no expected length is set.
+// If several of the options ExpectedRespLen, ExpectedRespLengths,
+// VariableRespLen, and ExpectedRespLenFunc are specified,
+// the last one takes effect.
+func ExpectedRespLenFunc(f func(reqData []byte) int) ReqOption {
+	return func(r *reqOptions) {
+		r.expectedLenFunc = f
 	}
 }
 
 func ExpectedRespLengths(l []int) ReqOption {
 	return func(r *reqOptions) {
 		r.expectedLenSpec = &ExpectedRespLenSpec{ValidLen: l}
+		r.expectedLenFunc = nil
+	}
+}
+
+// WithPreSendDelay is a request option that delays the transmission
+// of a request by d, e.g. to let a slow device wake up from a
+// low-power state. The delay may be interrupted by cancelling
+// the request's context.
+func WithPreSendDelay(d time.Duration) ReqOption {
+	return func(r *reqOptions) {
+		r.preSendDelay = d
 	}
 }
 
@@ -381,7 +604,20 @@ func WithRetryFunc(retry RetryFunc) ReqOption {
 	}
 }
 
+// NoRetry is a request option that disables any retries,
+// regardless of other retry options, and of their order.
+// It should be used for non-idempotent requests, like writes
+// to registers that increment a counter.
+func NoRetry() ReqOption {
+	return func(r *reqOptions) {
+		r.noRetry = true
+	}
+}
+
 func (rqo *reqOptions) canRetry(err error, n int) bool {
+	if rqo.noRetry {
+		return false
+	}
 	if retry := rqo.retryFunc; retry != nil {
 		if retry(err, n) {
 			return true
@@ -421,11 +657,46 @@ type VariableRespLenSpec struct {
 	TailLen       int
 }
 
+// VarLenFileRecord returns a VariableRespLenSpec suitable for
+// Read File Record (0x14) responses. Although such a response consists
+// of a sequence of length-prefixed sub-responses, its total length
+// is determined by the response data length in the byte following
+// the function code. Therefore the spec describes exactly one item,
+// the length of which is stored at index 1 of the PDU.
+func VarLenFileRecord() *VariableRespLenSpec {
+	return &VariableRespLenSpec{
+		NumItemsFixed: 1,
+		ItemLenIndex:  1,
+	}
+}
+
 // VariableRespLen is a request option that defines
 // a VariableRespLenSpec to be used during the request.
 func VariableRespLen(vs *VariableRespLenSpec) ReqOption {
 	return func(r *reqOptions) {
 		r.expectedLenSpec = &ExpectedRespLenSpec{Variable: vs}
+		r.expectedLenFunc = nil
+	}
+}
+
+// WithMinFrameLen is a request option that pads the request PDU
+// with trailing zero bytes up to a length of n bytes, including
+// the function code, but not exceeding the maximum request length.
+// It is meant as a workaround for devices rejecting short frames.
+// Broadcast requests are not padded.
+func WithMinFrameLen(n int) ReqOption {
+	return func(r *reqOptions) {
+		r.minFrameLen = n
+	}
+}
+
+// ExpectNoResponse is a request option that makes a request
+// behave like a broadcast: After the request has been sent,
+// and the turnaround delay has elapsed, Request returns
+// without waiting for a response.
+func ExpectNoResponse() ReqOption {
+	return func(r *reqOptions) {
+		r.noResponse = true
 	}
 }
 
@@ -435,6 +706,25 @@ func WithTraceFunc(f TraceFunc) ReqOption {
 	}
 }
 
+// WithLabel is a request option that attaches a key/value pair
+// to a request, e.g. a job ID or a register name. Labels do not
+// affect the messages sent; they are passed to a TraceEventFunc,
+// allowing trace output to be correlated with the originating operation.
+func WithLabel(key, value string) ReqOption {
+	return func(r *reqOptions) {
+		if r.labels == nil {
+			r.labels = make(map[string]string, 1)
+		}
+		r.labels[key] = value
+	}
+}
+
+func WithTraceEventFunc(f TraceEventFunc) ReqOption {
+	return func(r *reqOptions) {
+		r.traceEventf = f
+	}
+}
+
 // LimitLongTurnaroundTimes ensures that a request is rejected
 // if it is initiated too early after a previous request,
 // that took too long (e.g. several seconds) and thus blocked
@@ -452,13 +742,36 @@ func (netw *Network) Request(addr, fn uint8, req Request, resp Response, opts ..
 	rqo.ctx = context.TODO()
 	rqo.timeout = netw.ResponseTimeout
 	rqo.tracef = netw.Tracef
+	rqo.traceEventf = netw.TraceEventf
 	if i, ok := resp.(interface{ ExpectedLenSpec() *ExpectedRespLenSpec }); ok {
 		rqo.expectedLenSpec = i.ExpectedLenSpec()
 	}
 	for _, o := range opts {
 		o(&rqo)
 	}
-	trace := rqo.tracef.withNetConnName(netw.conn.Name())
+	if f := rqo.expectedLenFunc; f != nil {
+		data, err := EncodeData(req)
+		if err != nil {
+			return err
+		}
+		if n := f(data); n > 0 {
+			rqo.expectedLenSpec = &ExpectedRespLenSpec{ValidLen: []int{n}}
+		}
+	}
+	ctx, cancel := context.WithCancel(rqo.ctx)
+	rqo.ctx = ctx
+	netw.setAbortFunc(cancel)
+	defer func() {
+		netw.setAbortFunc(nil)
+		cancel()
+	}()
+	trace := &tracer{
+		f:       rqo.tracef,
+		evf:     rqo.traceEventf,
+		ncName:  netw.conn.Name(),
+		attempt: 1,
+		labels:  rqo.labels,
+	}
 
 	if minElapsed := rqo.longTurnaroundTime.minElapsedSincePrev; minElapsed != 0 {
 		if !netw.longTurnaroundTime.allowed(addr, minElapsed) {
@@ -479,17 +792,44 @@ retry:
 			return
 		}
 	}
-	if msgLen > 254 {
+	if addr != 0 && int(msgLen) < 1+rqo.minFrameLen {
+		n := 1 + rqo.minFrameLen
+		if max := 1 + netw.MaxRequestLen(); n > max {
+			n = max
+		}
+		if n > int(msgLen) {
+			mw.Write(make([]byte, n-int(msgLen)))
+		}
+	}
+	if int(msgLen) > 1+netw.MaxRequestLen() {
 		return ErrMaxReqLenExceeded
 	}
 
+	if d := rqo.preSendDelay; d > 0 {
+		t := time.NewTimer(d)
+		select {
+		case <-t.C:
+		case <-rqo.ctx.Done():
+			t.Stop()
+			return rqo.ctx.Err()
+		}
+	}
+	if si, ok := netw.conn.(sendInterceptorSetter); ok {
+		si.SetSendInterceptor(netw.SendInterceptor)
+	}
 	sentADU, err := netw.conn.Send()
 	trace.req(sentADU, err)
 	if err != nil {
 		return
 	}
-	if addr == 0 {
-		time.Sleep(netw.TurnaroundDelay)
+	if addr == 0 || rqo.noResponse {
+		if d := netw.turnaroundDelay(); d > 0 {
+			if fd, ok := netw.conn.(FrameDiscarder); ok && netw.QuietBroadcastWindow {
+				fd.DiscardFrames(rqo.ctx, d, netw.OnBroadcastResponse)
+			} else {
+				time.Sleep(d)
+			}
+		}
 		return
 	}
 
@@ -537,6 +877,7 @@ retry:
 		}
 		if rqo.canRetry(err, nRetries) {
 			nRetries++
+			trace.retry(err)
 			goto retry
 		}
 		return err
@@ -566,17 +907,27 @@ retry:
 		}
 		if rqo.canRetry(err, nRetries) {
 			nRetries++
+			trace.retry(err)
 			goto retry
 		}
 		return
 	}
 	if resp != nil {
 		err = resp.Decode(pdu[1:])
+		if err == nil && netw.TraceDecoded && trace.evf != nil {
+			if st, ok := resp.(fmt.Stringer); ok {
+				trace.decoded = st.String()
+			}
+		}
 	}
 	trace.resp(adu, err)
 	return
 }
 
+type sendInterceptorSetter interface {
+	SetSendInterceptor(func([]byte) []byte)
+}
+
 type msgLenCounter int
 
 func (lc *msgLenCounter) Write(data []byte) (int, error) {
@@ -650,28 +1001,88 @@ func (v *VariableRespLenSpec) Match(pdu []byte) (expectedLen int, match bool) {
 	return nx, n == nx
 }
 
+// An invalidReplier is an error, usually defined by a NetConn
+// implementation, that tells MsgInvalid whether it indicates
+// an invalid reply.
+type invalidReplier interface {
+	InvalidReply() bool
+}
+
 func MsgInvalid(err error) bool {
+	if e, ok := err.(invalidReplier); ok {
+		return e.InvalidReply()
+	}
 	if _, ok := err.(*InvalidLenError); ok {
 		return true
 	}
 	if _, ok := err.(*MismatchError); ok {
 		return true
 	}
+	if _, ok := err.(*LengthFieldMismatchError); ok {
+		return true
+	}
 	switch err {
 	default:
 		return false
+	case ErrUnexpectedEcho:
 	case ErrInvalidEchoLen:
+	case ErrEchoMismatch:
 	case ErrCRC:
+	case ErrMaxRespLenExceeded:
 	}
 	return true
 }
 
+// IsTransient reports whether err is likely caused by a temporary
+// condition, so that repeating the request may succeed. This is the case
+// for timeouts, invalid responses as recognized by MsgInvalid,
+// and the exceptions XACK and XDeviceBusy. Other exceptions, like
+// XIllegalFunc or XIllegalDataAddr, as well as a cancelled context
+// are considered permanent. An expired context deadline is considered
+// transient, since it may have been set for a single request only,
+// like a timeout. Wrapped errors are examined too.
+func IsTransient(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	var x Exception
+	if errors.As(err, &x) {
+		return x == XACK || x == XDeviceBusy
+	}
+	if errors.Is(err, ErrTimeout) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	for ; err != nil; err = errors.Unwrap(err) {
+		if MsgInvalid(err) {
+			return true
+		}
+	}
+	return false
+}
+
 type Request interface {
 	// Encode writes the data part of a PDU,
 	// i.e. the PDU without the function code.
 	Encode(io.Writer) error
 }
 
+// EncodeData returns the data part of a request PDU,
+// i.e. the PDU without the function code, as written by req.Encode.
+func EncodeData(req Request) ([]byte, error) {
+	if req == nil {
+		return nil, nil
+	}
+	var b bytes.Buffer
+	err := req.Encode(&b)
+	if err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
 type Response interface {
 	// Decode works on the data part of a PDU,
 	// i.e. the PDU without the function code.
@@ -0,0 +1,46 @@
+package modbus_test
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/knieriem/modbus"
+	"github.com/knieriem/modbus/internal/mocknet"
+)
+
+type rawData []byte
+
+func (d rawData) Encode(w io.Writer) error {
+	_, err := w.Write(d)
+	return err
+}
+
+func TestMaxRequestLen(t *testing.T) {
+	nc := mocknet.New(func(_ context.Context, req []byte, _ *modbus.ExpectedRespLenSpec) ([]byte, error) {
+		return req[:2], nil
+	})
+	netw := modbus.NewNetwork(nc)
+	if n := netw.MaxRequestLen(); n != modbus.MaxPDULen {
+		t.Errorf("MaxRequestLen: got %d, want %d", n, modbus.MaxPDULen)
+	}
+	if n := netw.MaxResponseLen(); n != modbus.MaxPDULen {
+		t.Errorf("MaxResponseLen: got %d, want %d", n, modbus.MaxPDULen)
+	}
+
+	// the function code counts as part of the PDU
+	err := netw.Request(1, 0x41, make(rawData, netw.MaxRequestLen()-1), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(nc.Sent); n != 1 {
+		t.Fatalf("%d requests sent, want 1", n)
+	}
+	err = netw.Request(1, 0x41, make(rawData, netw.MaxRequestLen()), nil)
+	if err != modbus.ErrMaxReqLenExceeded {
+		t.Errorf("got %v, want ErrMaxReqLenExceeded", err)
+	}
+	if n := len(nc.Sent); n != 1 {
+		t.Errorf("oversized request has been sent")
+	}
+}
@@ -0,0 +1,170 @@
+package modbus_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/knieriem/modbus"
+	"github.com/knieriem/modbus/internal/mocknet"
+)
+
+// timingOut is a mocknet.Handler of a device that never responds.
+func timingOut(context.Context, []byte, *modbus.ExpectedRespLenSpec) ([]byte, error) {
+	return nil, nil
+}
+
+func TestNoRetry(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		opts  []modbus.ReqOption
+		nSent int
+	}{
+		{"retry", []modbus.ReqOption{modbus.RetryOnTimeout(2, 0)}, 3},
+		{"NoRetry last", []modbus.ReqOption{modbus.RetryOnTimeout(2, 0), modbus.NoRetry()}, 1},
+		{"NoRetry first", []modbus.ReqOption{modbus.NoRetry(), modbus.RetryOnTimeout(2, 0)}, 1},
+		{"retry func", []modbus.ReqOption{modbus.NoRetry(), modbus.WithRetryFunc(func(error, int) bool { return true })}, 1},
+	} {
+		nc := mocknet.New(timingOut)
+		err := modbus.NewNetwork(nc).Request(1, 0x41, rawData{0, 1}, nil, tc.opts...)
+		if err != modbus.ErrTimeout {
+			t.Errorf("%s: got %v, want ErrTimeout", tc.name, err)
+		}
+		if n := len(nc.Sent); n != tc.nSent {
+			t.Errorf("%s: %d requests sent, want %d", tc.name, n, tc.nSent)
+		}
+	}
+}
+
+func TestPreSendDelay(t *testing.T) {
+	const delay = 30 * time.Millisecond
+	var sent []time.Time
+	nc := mocknet.New(func(context.Context, []byte, *modbus.ExpectedRespLenSpec) ([]byte, error) {
+		sent = append(sent, time.Now())
+		return nil, nil
+	})
+	netw := modbus.NewNetwork(nc)
+	t0 := time.Now()
+	err := netw.Request(1, 0x41, rawData{0, 1}, nil, modbus.WithPreSendDelay(delay), modbus.RetryOnTimeout(1, 0))
+	if err != modbus.ErrTimeout {
+		t.Fatalf("got %v, want ErrTimeout", err)
+	}
+	if len(sent) != 2 {
+		t.Fatalf("%d requests sent, want 2", len(sent))
+	}
+	// the delay precedes each attempt
+	for i, ts := range sent {
+		if d := ts.Sub(t0); d < delay {
+			t.Errorf("attempt %d sent after %v", i+1, d)
+		}
+		t0 = ts
+	}
+
+	// cancelling the context aborts the request during the delay
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+	}()
+	nc.Sent = nil
+	t0 = time.Now()
+	err = netw.Request(1, 0x41, rawData{0, 1}, nil, modbus.WithPreSendDelay(2*time.Second), modbus.WithContext(ctx))
+	if err != context.Canceled {
+		t.Errorf("got %v, want context.Canceled", err)
+	}
+	if d := time.Since(t0); d > time.Second {
+		t.Errorf("cancelled request returned after %v", d)
+	}
+	if len(nc.Sent) != 0 {
+		t.Error("request sent although cancelled")
+	}
+}
+
+func TestExpectedRespLenFunc(t *testing.T) {
+	// a response containing as many registers as requested
+	// in the count field, unless the count is 3
+	nc := mocknet.New(func(_ context.Context, req []byte, _ *modbus.ExpectedRespLenSpec) ([]byte, error) {
+		n := int(req[5])
+		if n == 3 {
+			n = 2
+		}
+		resp := []byte{req[0], req[1], byte(2 * n)}
+		return append(resp, make([]byte, 2*n)...), nil
+	})
+	netw := modbus.NewNetwork(nc)
+	respLen := func(reqData []byte) int {
+		if len(reqData) != 4 {
+			return 0
+		}
+		return 2 + 2*int(modbus.ByteOrder.Uint16(reqData[2:]))
+	}
+
+	for _, n := range []byte{1, 4} {
+		err := netw.Request(1, 3, rawData{0, 0, 0, n}, nil, modbus.ExpectedRespLenFunc(respLen))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ls := nc.Specs[len(nc.Specs)-1]
+		if want := 2 + 2*int(n); ls == nil || len(ls.ValidLen) != 1 || ls.ValidLen[0] != want {
+			t.Errorf("count %d: got length spec %+v, want %d", n, ls, want)
+		}
+	}
+
+	err := netw.Request(1, 3, rawData{0, 0, 0, 3}, nil, modbus.ExpectedRespLenFunc(respLen))
+	var il *modbus.InvalidLenError
+	if !errors.As(err, &il) {
+		t.Errorf("got %v, want an InvalidLenError", err)
+	}
+
+	// a result <= 0 sets no expected length
+	err = netw.Request(1, 3, rawData{0, 0, 0, 1, 0}, nil, modbus.ExpectedRespLenFunc(respLen))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ls := nc.Specs[len(nc.Specs)-1]; ls != nil {
+		t.Errorf("got length spec %+v, want none", ls)
+	}
+}
+
+func TestMinFrameLen(t *testing.T) {
+	nc := mocknet.New(func(_ context.Context, req []byte, _ *modbus.ExpectedRespLenSpec) ([]byte, error) {
+		return req[:2], nil
+	})
+	netw := modbus.NewNetwork(nc)
+	netw.TurnaroundDelay = 0
+
+	for _, tc := range []struct {
+		addr   uint8
+		data   rawData
+		minLen int
+		want   []byte
+	}{
+		// padded up to a PDU length of 6
+		{1, rawData{7}, 6, []byte{1, 0x41, 7, 0, 0, 0, 0}},
+		// long enough already
+		{1, rawData{7, 8, 9, 10, 11}, 6, []byte{1, 0x41, 7, 8, 9, 10, 11}},
+		// broadcasts are not padded
+		{0, rawData{7}, 6, []byte{0, 0x41, 7}},
+	} {
+		nc.Sent = nil
+		err := netw.Request(tc.addr, 0x41, tc.data, nil, modbus.WithMinFrameLen(tc.minLen))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(nc.Sent) != 1 || !bytes.Equal(nc.Sent[0], tc.want) {
+			t.Errorf("addr %d, data % x: sent % x, want % x", tc.addr, tc.data, nc.Sent, tc.want)
+		}
+	}
+
+	// padding does not exceed the maximum request length
+	nc.Sent = nil
+	err := netw.Request(1, 0x41, rawData{7}, nil, modbus.WithMinFrameLen(1000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(nc.Sent[0]); n != 1+netw.MaxRequestLen() {
+		t.Errorf("sent %d bytes, want %d", n, 1+netw.MaxRequestLen())
+	}
+}
@@ -0,0 +1,125 @@
+package modbus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestIsTransient(t *testing.T) {
+	for _, tc := range []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{ErrTimeout, true},
+		{ErrCRC, true},
+		{ErrEchoMismatch, true},
+		{ErrUnexpectedEcho, true},
+		{ErrInvalidEchoLen, true},
+		{ErrMaxRespLenExceeded, true},
+		{NewInvalidLen(MsgContextPDU, 3, 5), true},
+		{NewLengthFieldMismatch(4, 2), true},
+		{&MismatchError{Req: MsgHdr{1, 3}, Resp: MsgHdr{2, 3}}, true},
+		{XACK, true},
+		{XDeviceBusy, true},
+		{XIllegalFunc, false},
+		{XIllegalDataAddr, false},
+		{XIllegalDataVal, false},
+		{XDeviceFailure, false},
+		{XGwPathUnavail, false},
+		{ErrRejected, false},
+		{ErrMaxReqLenExceeded, false},
+		{context.Canceled, false},
+		{context.DeadlineExceeded, true},
+		{fmt.Errorf("wrapped: %w", context.Canceled), false},
+		{fmt.Errorf("wrapped: %w", context.DeadlineExceeded), true},
+		{fmt.Errorf("wrapped: %w", ErrTimeout), true},
+		{fmt.Errorf("wrapped: %w", ErrCRC), true},
+		{fmt.Errorf("wrapped: %w", NewInvalidLen(MsgContextPDU, 3, 5)), true},
+		{fmt.Errorf("wrapped: %w", XDeviceBusy), true},
+		{fmt.Errorf("wrapped: %w", XIllegalDataAddr), false},
+		{fmt.Errorf("wrapped: %w", ErrRejected), false},
+		{errors.New("other"), false},
+	} {
+		if got := IsTransient(tc.err); got != tc.want {
+			t.Errorf("IsTransient(%v): got %v, want %v", tc.err, got, tc.want)
+		}
+	}
+}
+
+func TestCanRetryInvalidReply(t *testing.T) {
+	invalid := []error{
+		ErrCRC,
+		ErrEchoMismatch,
+		ErrInvalidEchoLen,
+		ErrUnexpectedEcho,
+		ErrMaxRespLenExceeded,
+		NewInvalidLen(MsgContextPDU, 3, 5),
+		NewLengthFieldMismatch(4, 2),
+		&MismatchError{Req: MsgHdr{1, 3}, Resp: MsgHdr{2, 3}},
+	}
+	other := []error{
+		ErrTimeout,
+		XIllegalDataAddr,
+		ErrMaxReqLenExceeded,
+		context.Canceled,
+		errors.New("other"),
+	}
+	newOpts := func(opts ...ReqOption) *reqOptions {
+		rqo := new(reqOptions)
+		for _, o := range opts {
+			o(rqo)
+		}
+		return rqo
+	}
+	for _, err := range invalid {
+		rqo := newOpts(RetryOnInvalidReply(2, 0))
+		for n := 0; n < 2; n++ {
+			if !rqo.canRetry(err, n) {
+				t.Errorf("%v: retry %d not allowed", err, n+1)
+			}
+		}
+		if rqo.canRetry(err, 2) {
+			t.Errorf("%v: more retries than specified", err)
+		}
+		if newOpts().canRetry(err, 0) {
+			t.Errorf("%v: retried by default", err)
+		}
+		if newOpts(RetryOnInvalidReply(2, 0), NoRetry()).canRetry(err, 0) {
+			t.Errorf("%v: retried despite NoRetry", err)
+		}
+	}
+	for _, err := range other {
+		if newOpts(RetryOnInvalidReply(2, 0)).canRetry(err, 0) {
+			t.Errorf("%v: retried as invalid reply", err)
+		}
+	}
+}
+
+func TestVarLenFileRecord(t *testing.T) {
+	// response to a request of two records, from the
+	// example in the Modbus application protocol specification
+	pdu := []byte{
+		0x14, 0x0C,
+		0x05, 0x06, 0x0D, 0xFE, 0x00, 0x20,
+		0x05, 0x06, 0x33, 0xCD, 0x00, 0x40,
+	}
+	v := VarLenFileRecord()
+	for n := 0; n < len(pdu); n++ {
+		if _, ok := v.Match(pdu[:n]); ok {
+			t.Errorf("prefix of length %d accepted", n)
+		}
+	}
+	if n, ok := v.Match(pdu); !ok || n != len(pdu) {
+		t.Errorf("complete response not accepted: expected length %d", n)
+	}
+	if _, ok := v.Match(append(pdu, 0)); ok {
+		t.Error("response with trailing byte accepted")
+	}
+	ls := &ExpectedRespLenSpec{Variable: v}
+	if err := ls.CheckLen(pdu); err != nil {
+		t.Error(err)
+	}
+}
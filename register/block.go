@@ -0,0 +1,91 @@
+package register
+
+import (
+	"math"
+
+	"github.com/knieriem/modbus"
+)
+
+// A Block is a snapshot of a contiguous range of registers,
+// obtained by a single read request. Its accessors decode values
+// on demand, so that only the fields actually needed are
+// decoded. Offsets are specified in registers, relative to Start;
+// like slice indexing, an accessor panics if the value
+// is not contained in the block.
+type Block struct {
+	Start uint16
+	Data  []byte
+}
+
+// ReadBlock reads n registers starting at start using f,
+// which may be e.g. the ReadHoldingRegs or ReadInputRegs method
+// of a Device.
+func ReadBlock(f ReadFunc, start uint16, n int, opts ...modbus.ReqOption) (*Block, error) {
+	b := &Block{Start: start, Data: make([]byte, 2*n)}
+	err := f(start, b.Data, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Len returns the number of registers in the block.
+func (b *Block) Len() int {
+	return len(b.Data) / 2
+}
+
+// Offset returns the offset of register addr within the block.
+func (b *Block) Offset(addr uint16) int {
+	return int(addr) - int(b.Start)
+}
+
+func (b *Block) regs(offset, n int) []byte {
+	return b.Data[2*offset : 2*(offset+n)]
+}
+
+// value returns a copy of n registers at offset, converted
+// from the specified order to OrderBig.
+func (b *Block) value(offset, n int, order WordOrder) []byte {
+	v := make([]byte, 2*n)
+	copy(v, b.regs(offset, n))
+	order.toBig(v)
+	return v
+}
+
+func (b *Block) Uint16At(offset int) uint16 {
+	return modbus.ByteOrder.Uint16(b.regs(offset, 1))
+}
+
+func (b *Block) Int16At(offset int) int16 {
+	return int16(b.Uint16At(offset))
+}
+
+func (b *Block) Uint32At(offset int, order WordOrder) uint32 {
+	return modbus.ByteOrder.Uint32(b.value(offset, 2, order))
+}
+
+func (b *Block) Int32At(offset int, order WordOrder) int32 {
+	return int32(b.Uint32At(offset, order))
+}
+
+func (b *Block) Uint64At(offset int, order WordOrder) uint64 {
+	return modbus.ByteOrder.Uint64(b.value(offset, 4, order))
+}
+
+func (b *Block) Float32At(offset int, order WordOrder) float32 {
+	return math.Float32frombits(b.Uint32At(offset, order))
+}
+
+func (b *Block) Float64At(offset int, order WordOrder) float64 {
+	return math.Float64frombits(b.Uint64At(offset, order))
+}
+
+// StringAt decodes n registers at offset as a string
+// of packed bytes, the first character in the high byte.
+func (b *Block) StringAt(offset, n int, filters ...func([]byte) []byte) string {
+	s := append([]byte(nil), b.regs(offset, n)...)
+	for _, f := range filters {
+		s = f(s)
+	}
+	return string(s)
+}
@@ -0,0 +1,64 @@
+package register_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/knieriem/modbus"
+	"github.com/knieriem/modbus/internal/mocknet"
+	"github.com/knieriem/modbus/register"
+)
+
+func TestBlock(t *testing.T) {
+	data := []byte{
+		0x12, 0x34, // 100: 0x1234
+		0xFF, 0xFE, // 101: -2
+		0x00, 0x02, 0x00, 0x01, // 102: 0x10002, OrderLittleBS
+		0xC0, 0x3F, 0x00, 0x00, // 104: 1.5, OrderBigBS
+		8, 7, 6, 5, 4, 3, 2, 1, // 106: 0x0102030405060708, OrderLittle
+		'a', 'b', 'c', 0, // 110: "abc"
+	}
+	nReq := 0
+	nc := mocknet.New(func(_ context.Context, req []byte, _ *modbus.ExpectedRespLenSpec) ([]byte, error) {
+		nReq++
+		if start, n := modbus.ByteOrder.Uint16(req[2:]), modbus.ByteOrder.Uint16(req[4:]); start != 100 || n != 12 {
+			t.Errorf("request for %d registers at %d", n, start)
+		}
+		return append([]byte{req[0], req[1], byte(len(data))}, data...), nil
+	})
+	d := register.NewDevice(mocknet.Device(modbus.NewNetwork(nc), 1))
+
+	b, err := register.ReadBlock(d.ReadHoldingRegs, 100, 12)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nReq != 1 {
+		t.Errorf("%d requests, want 1", nReq)
+	}
+	if n := b.Len(); n != 12 {
+		t.Errorf("Len: %d", n)
+	}
+	if v := b.Uint16At(b.Offset(100)); v != 0x1234 {
+		t.Errorf("Uint16At: %#x", v)
+	}
+	if v := b.Int16At(b.Offset(101)); v != -2 {
+		t.Errorf("Int16At: %d", v)
+	}
+	if v := b.Uint32At(b.Offset(102), register.OrderLittleBS); v != 0x10002 {
+		t.Errorf("Uint32At: %#x", v)
+	}
+	if v := b.Float32At(b.Offset(104), register.OrderBigBS); v != 1.5 {
+		t.Errorf("Float32At: %v", v)
+	}
+	if v := b.Uint64At(b.Offset(106), register.OrderLittle); v != 0x0102030405060708 {
+		t.Errorf("Uint64At: %#x", v)
+	}
+	if s := b.StringAt(b.Offset(110), 2, register.StopAtZero); s != "abc" {
+		t.Errorf("StringAt: %q", s)
+	}
+
+	// accessors do not modify the block
+	if v := b.Uint32At(b.Offset(102), register.OrderLittleBS); v != 0x10002 {
+		t.Errorf("Uint32At, second access: %#x", v)
+	}
+}
@@ -0,0 +1,65 @@
+package register
+
+import (
+	"io"
+
+	"github.com/knieriem/modbus"
+)
+
+const maxReadCoils = 2000
+
+// readCoils contains both the request and the response of
+// ReadCoilsInto, including the expected response length spec,
+// so that a single allocation is needed per request.
+type readCoils struct {
+	req  [4]byte
+	dst  []bool
+	spec modbus.ExpectedRespLenSpec
+	lens [1]int
+}
+
+func (r *readCoils) Encode(w io.Writer) error {
+	_, err := w.Write(r.req[:])
+	return err
+}
+
+func (r *readCoils) ExpectedLenSpec() *modbus.ExpectedRespLenSpec {
+	return &r.spec
+}
+
+func (r *readCoils) Decode(buf []byte) error {
+	if len(buf) < 1 {
+		return modbus.NewInvalidLen(modbus.MsgContextData, len(buf), 1)
+	}
+	data := buf[1:]
+	if int(buf[0]) != len(data) {
+		return modbus.NewLengthFieldMismatch(int(buf[0]), len(data))
+	}
+	if n := (len(r.dst) + 7) / 8; len(data) != n {
+		return modbus.NewInvalidLen(modbus.MsgContextData, len(data), n)
+	}
+	for i := range r.dst {
+		r.dst[i] = data[i/8]&(1<<(i%8)) != 0
+	}
+	return nil
+}
+
+// ReadCoilsInto reads len(dst) coils, starting at start, into dst.
+// Since dst is provided by the caller, it may be reused
+// across polls, avoiding allocations for the result.
+func (d *Device) ReadCoilsInto(start uint16, dst []bool, opts ...modbus.ReqOption) error {
+	n := len(dst)
+	if n == 0 || n > maxReadCoils {
+		return Error("number of coils out of range")
+	}
+	_, _, err := AddrRange(start, uint16(n))
+	if err != nil {
+		return err
+	}
+	r := &readCoils{dst: dst}
+	modbus.ByteOrder.PutUint16(r.req[0:], start)
+	modbus.ByteOrder.PutUint16(r.req[2:], uint16(n))
+	r.lens[0] = 1 + 1 + (n+7)/8
+	r.spec.ValidLen = r.lens[:]
+	return d.Request(uint8(modbus.FnReadCoils), r, r, opts...)
+}
@@ -0,0 +1,91 @@
+package register_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/knieriem/modbus"
+	"github.com/knieriem/modbus/register"
+)
+
+// A coilDevice answers Read Coils requests without allocating;
+// coils at addresses divisible by three are set. If checkSpec
+// is true, the expected length spec of the response is verified.
+type coilDevice struct {
+	req       bytes.Buffer
+	resp      [2 + 250]byte
+	checkSpec bool
+}
+
+func (d *coilDevice) Request(fn uint8, req modbus.Request, resp modbus.Response, _ ...modbus.ReqOption) error {
+	d.req.Reset()
+	err := req.Encode(&d.req)
+	if err != nil {
+		return err
+	}
+	b := d.req.Bytes()
+	start, n := modbus.ByteOrder.Uint16(b), int(modbus.ByteOrder.Uint16(b[2:]))
+	pdu := d.resp[:2+(n+7)/8]
+	pdu[0] = fn
+	pdu[1] = byte(len(pdu) - 2)
+	for i := range pdu[2:] {
+		pdu[2+i] = 0
+	}
+	for i := 0; i < n; i++ {
+		if (int(start)+i)%3 == 0 {
+			pdu[2+i/8] |= 1 << (i % 8)
+		}
+	}
+	if ls, ok := resp.(lenSpecer); ok && d.checkSpec {
+		if err := ls.ExpectedLenSpec().CheckLen(pdu); err != nil {
+			return err
+		}
+		if ls.ExpectedLenSpec().CheckLen(pdu[:len(pdu)-1]) == nil {
+			return modbus.Error("truncated response accepted")
+		}
+	} else if d.checkSpec {
+		return modbus.Error("no expected length spec")
+	}
+	return resp.Decode(pdu[1:])
+}
+
+type lenSpecer interface {
+	ExpectedLenSpec() *modbus.ExpectedRespLenSpec
+}
+
+func TestReadCoilsInto(t *testing.T) {
+	d := register.NewDevice(&coilDevice{checkSpec: true})
+	dst := make([]bool, 10)
+	for _, start := range []uint16{0, 1} {
+		err := d.ReadCoilsInto(start, dst)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, v := range dst {
+			if want := (int(start)+i)%3 == 0; v != want {
+				t.Errorf("start %d: coil %d is %v", start, i, v)
+			}
+		}
+	}
+	if err := d.ReadCoilsInto(0, make([]bool, 2001)); err == nil {
+		t.Error("2001 coils accepted")
+	}
+	if err := d.ReadCoilsInto(0xFFFF, dst); err == nil {
+		t.Error("address overflow accepted")
+	}
+}
+
+func TestReadCoilsIntoAllocs(t *testing.T) {
+	d := register.NewDevice(new(coilDevice))
+	small := make([]bool, 8)
+	large := make([]bool, 2000)
+	nSmall := testing.AllocsPerRun(100, func() {
+		d.ReadCoilsInto(0, small)
+	})
+	nLarge := testing.AllocsPerRun(100, func() {
+		d.ReadCoilsInto(0, large)
+	})
+	if nSmall > 1 || nLarge != nSmall {
+		t.Errorf("allocations per call: %v for 8 coils, %v for 2000 coils; want at most 1", nSmall, nLarge)
+	}
+}
@@ -0,0 +1,118 @@
+package register
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+
+	"github.com/knieriem/modbus"
+)
+
+type maskWriteReg struct {
+	addr    uint16
+	andMask uint16
+	orMask  uint16
+
+	byteOrder binary.ByteOrder
+}
+
+func (r *maskWriteReg) Encode(w io.Writer) error {
+	var b [6]byte
+	modbus.ByteOrder.PutUint16(b[0:], r.addr)
+	r.byteOrder.PutUint16(b[2:], r.andMask)
+	r.byteOrder.PutUint16(b[4:], r.orMask)
+	_, err := w.Write(b[:])
+	return err
+}
+
+type maskWriteRegResp struct {
+	req *maskWriteReg
+}
+
+func (r *maskWriteRegResp) Decode(buf []byte) error {
+	if len(buf) != 6 {
+		return modbus.NewInvalidLen(modbus.MsgContextData, len(buf), 6)
+	}
+	var b bytes.Buffer
+	r.req.Encode(&b)
+	if !bytes.Equal(buf, b.Bytes()) {
+		return Error("mask write register response does not match the request")
+	}
+	return nil
+}
+
+// MaskWriteReg modifies the contents of a holding register using
+// the Mask Write Register function (0x16). The new value is
+// computed by the device as
+//
+//	(current & andMask) | (orMask & ^andMask)
+//
+// Like register values, the masks are encoded
+// using the byte order of the Device.
+func (d *Device) MaskWriteReg(regAddr, andMask, orMask uint16, opts ...modbus.ReqOption) error {
+	req := &maskWriteReg{addr: regAddr, andMask: andMask, orMask: orMask, byteOrder: d.order()}
+	opts = append(opts, modbus.ExpectedRespLen(1+6))
+	return d.Request(uint8(modbus.FnMaskWriteReg), req, &maskWriteRegResp{req: req}, opts...)
+}
+
+// A Field is a group of Width adjacent bits within
+// a holding register, starting at bit Shift.
+type Field struct {
+	Addr  uint16
+	Shift uint
+	Width uint
+}
+
+var ErrInvalidField = Error("invalid field")
+
+func (f Field) validate() error {
+	if f.Width == 0 || f.Shift+f.Width > 16 {
+		return ErrInvalidField
+	}
+	return nil
+}
+
+func (f Field) mask() uint16 {
+	return uint16((1<<f.Width)-1) << f.Shift
+}
+
+// Read reads the register containing the field,
+// and returns the value of the field.
+func (f Field) Read(d *Device, opts ...modbus.ReqOption) (uint16, error) {
+	err := f.validate()
+	if err != nil {
+		return 0, err
+	}
+	var reg uint16
+	err = d.ReadHoldingRegs(f.Addr, &reg, opts...)
+	if err != nil {
+		return 0, err
+	}
+	return (reg & f.mask()) >> f.Shift, nil
+}
+
+// Write sets the field to v, leaving the other bits of the register
+// unchanged. It uses the Mask Write Register function; if the device
+// does not support it, the register is read, modified,
+// and written back instead.
+func (f Field) Write(d *Device, v uint16, opts ...modbus.ReqOption) error {
+	err := f.validate()
+	if err != nil {
+		return err
+	}
+	mask := f.mask()
+	if v > mask>>f.Shift {
+		return Error("value exceeds field width")
+	}
+	v <<= f.Shift
+	err = d.MaskWriteReg(f.Addr, ^mask, v, opts...)
+	if err != modbus.XIllegalFunc {
+		return err
+	}
+	var reg uint16
+	err = d.ReadHoldingRegs(f.Addr, &reg, opts...)
+	if err != nil {
+		return err
+	}
+	return d.WriteReg(f.Addr, reg&^mask|v, opts...)
+}
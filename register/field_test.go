@@ -0,0 +1,99 @@
+package register_test
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/knieriem/modbus"
+	"github.com/knieriem/modbus/internal/mocknet"
+	"github.com/knieriem/modbus/register"
+	"github.com/knieriem/modbus/server"
+)
+
+// maskBus extends a server.Handler by the Mask Write Register function.
+type maskBus struct {
+	*server.Handler
+	store     *server.MapStore
+	maskWrite int
+}
+
+func (b *maskBus) Request(addr, fn uint8, req modbus.Request, resp modbus.Response, opts ...modbus.ReqOption) error {
+	if modbus.FuncCode(fn) != modbus.FnMaskWriteReg {
+		return b.Handler.Request(addr, fn, req, resp, opts...)
+	}
+	b.maskWrite++
+	data, err := modbus.EncodeData(req)
+	if err != nil {
+		return err
+	}
+	bo := modbus.ByteOrder
+	reg, and, or := bo.Uint16(data), bo.Uint16(data[2:]), bo.Uint16(data[4:])
+	cur, ok := b.store.Holding[reg]
+	if !ok {
+		return modbus.XIllegalDataAddr
+	}
+	b.store.Holding[reg] = cur&and | or&^and
+	return resp.Decode(data)
+}
+
+func TestField(t *testing.T) {
+	for _, withMask := range []bool{true, false} {
+		for _, order := range []binary.ByteOrder{binary.BigEndian, binary.LittleEndian} {
+			store := server.NewMapStore()
+			store.Holding[7] = 0
+			mb := &maskBus{Handler: server.NewHandler(store), store: store}
+			var bus modbus.Bus = mb
+			if !withMask {
+				bus = mb.Handler
+			}
+			d := register.NewDeviceWithOrder(mocknet.Device(bus, 1), order)
+
+			err := d.WriteReg(7, uint16(0xABCD))
+			if err != nil {
+				t.Fatal(err)
+			}
+			f := register.Field{Addr: 7, Shift: 4, Width: 4}
+			v, err := f.Read(d)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if v != 0xC {
+				t.Errorf("mask write %v, %v: read %x, want c", withMask, order, v)
+			}
+			err = f.Write(d, 5)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var reg uint16
+			err = d.ReadHoldingRegs(7, &reg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if reg != 0xAB5D {
+				t.Errorf("mask write %v, %v: register is %04x, want ab5d", withMask, order, reg)
+			}
+			if withMask && mb.maskWrite != 1 {
+				t.Errorf("%v: mask write register used %d times", order, mb.maskWrite)
+			}
+		}
+	}
+}
+
+func TestFieldInvalid(t *testing.T) {
+	d := register.NewDevice(mocknet.Device(server.NewHandler(server.NewMapStore()), 1))
+	for _, f := range []register.Field{
+		{Width: 0},
+		{Shift: 12, Width: 5},
+	} {
+		if _, err := f.Read(d); err != register.ErrInvalidField {
+			t.Errorf("read %+v: got %v", f, err)
+		}
+		if err := f.Write(d, 0); err != register.ErrInvalidField {
+			t.Errorf("write %+v: got %v", f, err)
+		}
+	}
+	f := register.Field{Addr: 1, Shift: 4, Width: 4}
+	if err := f.Write(d, 16); err == nil {
+		t.Error("value exceeding the width accepted")
+	}
+}
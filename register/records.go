@@ -0,0 +1,148 @@
+package register
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"reflect"
+
+	"github.com/knieriem/modbus"
+)
+
+// A WordOrder defines how the bytes of a multi-register value
+// are arranged on the wire. The orders correspond to the
+// Float32Big, Float32BigBS, Float32LittleBS, and Float32Little types.
+type WordOrder int
+
+const (
+	// OrderBig is the order as specified by Modbus:
+	// the most significant word first, each word big endian.
+	OrderBig WordOrder = iota
+
+	// OrderBigBS is like OrderBig, but with the bytes
+	// of each word swapped.
+	OrderBigBS
+
+	// OrderLittleBS is the least significant word first,
+	// each word big endian.
+	OrderLittleBS
+
+	// OrderLittle is the least significant word first,
+	// each word little endian.
+	OrderLittle
+)
+
+// toBig converts the bytes of a single value in place
+// from order o to OrderBig, or vice versa.
+func (o WordOrder) toBig(b []byte) {
+	n := len(b)
+	switch o {
+	case OrderBigBS:
+		for i := 0; i+1 < n; i += 2 {
+			b[i], b[i+1] = b[i+1], b[i]
+		}
+	case OrderLittleBS:
+		for i, j := 0, n-2; i < j; i, j = i+2, j-2 {
+			b[i], b[i+1], b[j], b[j+1] = b[j], b[j+1], b[i], b[i+1]
+		}
+	case OrderLittle:
+		for i, j := 0, n-1; i < j; i, j = i+1, j-1 {
+			b[i], b[j] = b[j], b[i]
+		}
+	}
+}
+
+// valueSizes appends the sizes of the basic values contained
+// in a type compatible with encoding/binary to list.
+func valueSizes(list []int, t reflect.Type) []int {
+	switch t.Kind() {
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			list = valueSizes(list, t.Field(i).Type)
+		}
+	case reflect.Array:
+		for i := 0; i < t.Len(); i++ {
+			list = valueSizes(list, t.Elem())
+		}
+	default:
+		list = append(list, int(t.Size()))
+	}
+	return list
+}
+
+// pairBytes combines each two adjacent 1-byte values into
+// a single register sized value, so that, for the byte swapping
+// orders, their bytes are swapped per register. It returns
+// an error if a 1-byte value does not share a register with another one.
+func pairBytes(sizes []int) ([]int, error) {
+	out := sizes[:0]
+	for i := 0; i < len(sizes); i++ {
+		size := sizes[i]
+		if size == 1 {
+			if i+1 == len(sizes) || sizes[i+1] != 1 {
+				return nil, errors.New("8-bit values must be paired within a register")
+			}
+			i++
+			size = 2
+		}
+		out = append(out, size)
+	}
+	return out, nil
+}
+
+// ReadRecords reads a sequence of records from holding registers,
+// starting at register start. Out must be a pointer to a slice of
+// structs of a fixed size; the length of the slice determines
+// the number of records read. Each value contained in the records
+// is decoded according to the specified word order. Values of
+// 8-bit types, like uint8 or arrays of bytes, must be arranged
+// in pairs, each pair forming a register; the bytes of a
+// register are swapped in case of OrderBigBS and OrderLittle.
+func ReadRecords(d *Device, start uint16, out interface{}, order WordOrder, opts ...modbus.ReqOption) error {
+	v := reflect.ValueOf(out)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Slice {
+		return errors.New("out must be a pointer to a slice")
+	}
+	sl := v.Elem()
+	et := sl.Type().Elem()
+	if et.Kind() != reflect.Struct {
+		return errors.New("slice elements must be structs")
+	}
+	recSize := binary.Size(reflect.Zero(et).Interface())
+	if recSize == -1 {
+		return errors.New("record type not compatible with encoding/binary package")
+	}
+	if recSize&1 != 0 {
+		return errors.New("record size does not equal a multiple of two")
+	}
+	sizes, err := pairBytes(valueSizes(nil, et))
+	if err != nil {
+		return err
+	}
+	n := sl.Len()
+	buf := make([]byte, n*recSize)
+	if len(buf) == 0 {
+		return nil
+	}
+	err = d.ReadHoldingRegs(start, buf, opts...)
+	if err != nil {
+		return err
+	}
+	for b := buf; len(b) != 0; {
+		for _, size := range sizes {
+			order.toBig(b[:size])
+			b = b[size:]
+		}
+	}
+	return binary.Read(bytes.NewReader(buf), binary.BigEndian, sl.Interface())
+}
+
+// Float32Bytes returns the wire representation of v, arranged
+// according to order, suitable for writing two holding registers
+// using WriteRegs. The result is consistent with the
+// Float32Big, Float32BigBS, Float32LittleBS, and Float32Little types.
+func Float32Bytes(v float32, order WordOrder) (b [4]byte) {
+	encodeFloat32(b[:], v)
+	order.toBig(b[:])
+	return b
+}
@@ -0,0 +1,106 @@
+package register_test
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/knieriem/modbus/register"
+)
+
+type record struct {
+	ID    uint16
+	Value float32
+	Flags [2]uint8
+	Count int32
+}
+
+// wireRecord returns the wire representation of r
+// arranged according to order.
+func wireRecord(r record, order register.WordOrder) []byte {
+	reg := func(b0, b1 byte) []byte {
+		if order == register.OrderBigBS || order == register.OrderLittle {
+			return []byte{b1, b0}
+		}
+		return []byte{b0, b1}
+	}
+	dword := func(v uint32) []byte {
+		var b [4]byte
+		binary.BigEndian.PutUint32(b[:], v)
+		switch order {
+		case register.OrderBigBS:
+			return []byte{b[1], b[0], b[3], b[2]}
+		case register.OrderLittleBS:
+			return []byte{b[2], b[3], b[0], b[1]}
+		case register.OrderLittle:
+			return []byte{b[3], b[2], b[1], b[0]}
+		}
+		return b[:]
+	}
+	var w []byte
+	w = append(w, reg(byte(r.ID>>8), byte(r.ID))...)
+	w = append(w, dword(math.Float32bits(r.Value))...)
+	w = append(w, reg(r.Flags[0], r.Flags[1])...)
+	w = append(w, dword(uint32(r.Count))...)
+	return w
+}
+
+func TestReadRecords(t *testing.T) {
+	want := []record{
+		{1, 0.5, [2]uint8{0x01, 0x81}, -1000},
+		{2, 1.5, [2]uint8{0x02, 0x82}, -2000},
+		{3, 2.5, [2]uint8{0x03, 0x83}, 70000},
+	}
+	for _, order := range []register.WordOrder{register.OrderBig, register.OrderBigBS, register.OrderLittleBS, register.OrderLittle} {
+		var data []byte
+		for _, r := range want {
+			data = append(data, wireRecord(r, order)...)
+		}
+		dev := &fixedRespDevice{pdu: append([]byte{3, byte(len(data))}, data...)}
+		recs := make([]record, len(want))
+		err := register.ReadRecords(register.NewDevice(dev), 0, &recs, order)
+		if err != nil {
+			t.Fatalf("order %d: %v", order, err)
+		}
+		for i := range want {
+			if recs[i] != want[i] {
+				t.Errorf("order %d, record %d: got %+v, want %+v", order, i, recs[i], want[i])
+			}
+		}
+	}
+
+	// a single byte value must share a register with another one
+	type unpaired struct {
+		A uint8
+		B int16
+		C uint8
+	}
+	dev := &fixedRespDevice{pdu: []byte{3, 4, 0, 0, 0, 0}}
+	recs := make([]unpaired, 1)
+	if err := register.ReadRecords(register.NewDevice(dev), 0, &recs, register.OrderBig); err == nil {
+		t.Error("unpaired 8-bit values accepted")
+	}
+}
+
+func TestFloat32Bytes(t *testing.T) {
+	const v = float32(-1234.5)
+	orders := []struct {
+		order  register.WordOrder
+		decode func([4]byte) float32
+	}{
+		{register.OrderBig, func(b [4]byte) float32 { return math.Float32frombits(binary.BigEndian.Uint32(b[:])) }},
+		{register.OrderBigBS, func(b [4]byte) float32 { return register.Float32BigBS(b).Value() }},
+		{register.OrderLittleBS, func(b [4]byte) float32 { return register.Float32LittleBS(b).Value() }},
+		{register.OrderLittle, func(b [4]byte) float32 { return register.Float32Little(b).Value() }},
+	}
+	for _, o := range orders {
+		b := register.Float32Bytes(v, o.order)
+		if got := o.decode(b); got != v {
+			t.Errorf("order %d: decoded %v, want %v", o.order, got, v)
+		}
+		blk := &register.Block{Data: b[:]}
+		if got := blk.Float32At(0, o.order); got != v {
+			t.Errorf("order %d: Block.Float32At returned %v, want %v", o.order, got, v)
+		}
+	}
+}
@@ -4,7 +4,9 @@ import (
 	"bytes"
 	"encoding/binary"
 	"errors"
+	"fmt"
 	"io"
+	"reflect"
 	"strconv"
 	"strings"
 
@@ -13,10 +15,59 @@ import (
 
 type Device struct {
 	modbus.Device
+
+	byteOrder           binary.ByteOrder
+	byteCountIsRegCount bool
+	noByteCount         bool
 }
 
-func NewDevice(d modbus.Device) *Device {
-	return &Device{Device: d}
+// A DeviceOption configures optional, mostly compatibility
+// related, behaviour of a Device.
+type DeviceOption func(*Device)
+
+func NewDevice(d modbus.Device, opts ...DeviceOption) *Device {
+	dev := &Device{Device: d, byteOrder: modbus.ByteOrder}
+	for _, o := range opts {
+		o(dev)
+	}
+	return dev
+}
+
+// NewDeviceWithOrder creates a Device that uses the specified
+// byte order, instead of modbus.ByteOrder, when encoding
+// and decoding register values. The order of the address and
+// count fields of a request is not affected.
+func NewDeviceWithOrder(d modbus.Device, order binary.ByteOrder, opts ...DeviceOption) *Device {
+	dev := NewDevice(d, opts...)
+	dev.byteOrder = order
+	return dev
+}
+
+func (d *Device) order() binary.ByteOrder {
+	if d.byteOrder == nil {
+		return modbus.ByteOrder
+	}
+	return d.byteOrder
+}
+
+// WithByteCountIsRegCount makes a Device interpret the byte count
+// field of read register responses as the number of registers,
+// as done by some non-conforming devices. The expected length of the
+// data following the field is then twice the field value.
+func WithByteCountIsRegCount() DeviceOption {
+	return func(d *Device) {
+		d.byteCountIsRegCount = true
+	}
+}
+
+// WithoutByteCount makes a Device accept read register responses
+// lacking the byte count field, as sent by some non-conforming devices.
+// The complete data part of the response is decoded as register data,
+// its expected size being derived from the destination buffer.
+func WithoutByteCount() DeviceOption {
+	return func(d *Device) {
+		d.noByteCount = true
+	}
 }
 
 type Error string
@@ -28,23 +79,54 @@ func (e Error) Error() string {
 type ReadFunc func(regAddr uint16, data interface{}, opts ...modbus.ReqOption) error
 
 type readRegistersResp struct {
-	numBytes byte
-	buf      interface{}
+	numBytes         byte
+	buf              interface{}
+	byteOrder        binary.ByteOrder
+	lenFieldIsNumReg bool
+	noLenField       bool
 }
 
 func (r *readRegistersResp) Decode(buf []byte) (err error) {
+	if r.noLenField {
+		if n := binary.Size(r.buf); len(buf) != n {
+			return modbus.NewInvalidLen(modbus.MsgContextData, len(buf), n)
+		}
+		return binary.Read(bytes.NewReader(buf), r.byteOrder, r.buf)
+	}
 	if len(buf) < 1 {
 		return modbus.NewInvalidLen(modbus.MsgContextData, len(buf), 1)
 	}
 	r.numBytes = buf[0]
 	data := buf[1:]
-	if int(r.numBytes) != len(data) {
-		return modbus.NewLengthFieldMismatch(int(r.numBytes), len(data))
+	n := int(r.numBytes)
+	if r.lenFieldIsNumReg {
+		n *= 2
 	}
-	err = binary.Read(bytes.NewReader(data), modbus.ByteOrder, r.buf)
+	if n != len(data) {
+		return &modbus.LengthFieldMismatchError{Field: int(r.numBytes), ExpectedLen: n, DataLen: len(data)}
+	}
+	err = binary.Read(bytes.NewReader(data), r.byteOrder, r.buf)
 	return
 }
 
+func (r *readRegistersResp) String() string {
+	return fmt.Sprint(reflect.Indirect(reflect.ValueOf(r.buf)).Interface())
+}
+
+// ReadRespLen returns the PDU length of a response to a
+// read registers request, that returns nBytes of register data:
+// function code, byte count, and the data.
+func ReadRespLen(nBytes int) int {
+	return 1 + 1 + nBytes
+}
+
+// WriteRespLen returns the PDU length of a response to a
+// write single register or write multiple registers request:
+// function code, address, and value or register count.
+func WriteRespLen() int {
+	return 1 + 2 + 2
+}
+
 type readRegisters struct {
 	Start uint16
 	N     uint16
@@ -55,6 +137,26 @@ func (r *readRegisters) Encode(w io.Writer) (err error) {
 	return
 }
 
+var (
+	ErrAddrOverflow   = Error("address range exceeds 0xFFFF")
+	ErrEmptyAddrRange = Error("empty address range")
+)
+
+// AddrRange validates a range of count registers starting
+// at start, and returns the first and the last address.
+// It returns ErrAddrOverflow if the range wraps around
+// the end of the address space, and ErrEmptyAddrRange
+// if count is zero.
+func AddrRange(start, count uint16) (first, last uint16, err error) {
+	if count == 0 {
+		return 0, 0, ErrEmptyAddrRange
+	}
+	if int(start)+int(count) > 0x10000 {
+		return 0, 0, ErrAddrOverflow
+	}
+	return start, start + count - 1, nil
+}
+
 func (d *Device) readRegs(fn uint8, startAddr uint16, dest interface{}, opts []modbus.ReqOption) (err error) {
 	var resp readRegistersResp
 
@@ -62,18 +164,29 @@ func (d *Device) readRegs(fn uint8, startAddr uint16, dest interface{}, opts []m
 	if err != nil {
 		return
 	}
+	_, _, err = AddrRange(startAddr, nReg)
+	if err != nil {
+		return
+	}
 	resp.buf = dest
-	opts = append(opts, modbus.ExpectedRespLen(1+1+nBytes))
+	resp.byteOrder = d.order()
+	resp.lenFieldIsNumReg = d.byteCountIsRegCount
+	resp.noLenField = d.noByteCount
+	respLen := ReadRespLen(nBytes)
+	if d.noByteCount {
+		respLen--
+	}
+	opts = append(opts, modbus.ExpectedRespLen(respLen))
 	err = d.Request(fn, &readRegisters{Start: startAddr, N: nReg}, &resp, opts...)
 	return
 }
 
 func (d *Device) ReadHoldingRegs(startReg uint16, dest interface{}, opts ...modbus.ReqOption) error {
-	return d.readRegs(3, startReg, dest, opts)
+	return d.readRegs(uint8(modbus.FnReadHolding), startReg, dest, opts)
 }
 
 func (d *Device) ReadInputRegs(startReg uint16, dest interface{}, opts ...modbus.ReqOption) error {
-	return d.readRegs(4, startReg, dest, opts)
+	return d.readRegs(uint8(modbus.FnReadInput), startReg, dest, opts)
 }
 
 type singleReg struct {
@@ -87,10 +200,29 @@ func (r *singleReg) Encode(w io.Writer) (err error) {
 }
 
 func (d *Device) WriteReg(regAddr uint16, data interface{}, opts ...modbus.ReqOption) (err error) {
+	return d.writeReg(regAddr, data, nil, opts)
+}
+
+// WriteRegEcho writes a single register like WriteReg, but
+// returns the register value echoed by the device. This allows to detect
+// whether the device stored a value different from the one written,
+// e.g. because it has been clamped.
+func (d *Device) WriteRegEcho(regAddr uint16, data interface{}, opts ...modbus.ReqOption) (uint16, error) {
+	var resp singleRegResp
+
+	resp.addr = regAddr
+	err := d.writeReg(regAddr, data, &resp, opts)
+	if err != nil {
+		return 0, err
+	}
+	return d.order().Uint16(resp.value[:]), nil
+}
+
+func (d *Device) writeReg(regAddr uint16, data interface{}, resp modbus.Response, opts []modbus.ReqOption) (err error) {
 	var value [2]byte
 
 	buf := bytes.NewBuffer(value[:0])
-	err = binary.Write(buf, modbus.ByteOrder, data)
+	err = binary.Write(buf, d.order(), data)
 	if err != nil {
 		return
 	}
@@ -99,16 +231,34 @@ func (d *Device) WriteReg(regAddr uint16, data interface{}, opts ...modbus.ReqOp
 		return
 	}
 	copy(value[:], buf.Bytes())
-	opts = append(opts, modbus.ExpectedRespLen(1+2+2))
-	err = d.Request(6, &singleReg{Addr: regAddr, Value: value}, nil, opts...)
+	opts = append(opts, modbus.ExpectedRespLen(WriteRespLen()))
+	err = d.Request(uint8(modbus.FnWriteSingleReg), &singleReg{Addr: regAddr, Value: value}, resp, opts...)
 	return
 }
 
+type singleRegResp struct {
+	addr  uint16
+	value [2]byte
+}
+
+func (r *singleRegResp) Decode(buf []byte) error {
+	if len(buf) != 4 {
+		return modbus.NewInvalidLen(modbus.MsgContextData, len(buf), 4)
+	}
+	if modbus.ByteOrder.Uint16(buf) != r.addr {
+		return Error("register address of response does not match")
+	}
+	copy(r.value[:], buf[2:])
+	return nil
+}
+
 type multipleRegs struct {
 	Addr   uint16
 	NRegs  uint16
 	NBytes uint8
 	Values interface{}
+
+	byteOrder binary.ByteOrder
 }
 
 type Encoder interface {
@@ -122,7 +272,7 @@ func (r *multipleRegs) Encode(w io.Writer) (err error) {
 	if e, ok := r.Values.(Encoder); ok {
 		err = e.Encode(w)
 	} else {
-		err = binary.Write(w, modbus.ByteOrder, r.Values)
+		err = binary.Write(w, r.byteOrder, r.Values)
 	}
 	return
 }
@@ -132,15 +282,54 @@ func (d *Device) WriteRegs(startAddr uint16, data interface{}, opts ...modbus.Re
 	if err != nil {
 		return
 	}
+	_, _, err = AddrRange(startAddr, nReg)
+	if err != nil {
+		return
+	}
 	if nReg == 1 {
 		err = d.WriteReg(startAddr, data, opts...)
 		return
 	}
-	opts = append(opts, modbus.ExpectedRespLen(1+2+2))
-	err = d.Request(0x10, &multipleRegs{Addr: startAddr, NRegs: nReg, NBytes: uint8(nBytes), Values: data}, nil, opts...)
+	opts = append(opts, modbus.ExpectedRespLen(WriteRespLen()))
+	err = d.Request(uint8(modbus.FnWriteMultiRegs), &multipleRegs{Addr: startAddr, NRegs: nReg, NBytes: uint8(nBytes), Values: data, byteOrder: d.order()}, nil, opts...)
 	return
 }
 
+// maxCoalesceSpan is the maximum number of registers read
+// in a single request to obtain two non-adjacent registers.
+const maxCoalesceSpan = 8
+
+// ReadSplitUint32 reads a 32-bit value from two holding registers
+// that are not necessarily adjacent, as found in some legacy devices.
+// If the registers are close to each other, they are read
+// using a single request, otherwise two requests are issued.
+func ReadSplitUint32(d *Device, hiAddr, loAddr uint16, opts ...modbus.ReqOption) (uint32, error) {
+	var hi, lo uint16
+
+	start, end := hiAddr, loAddr
+	if start > end {
+		start, end = end, start
+	}
+	if span := int(end) - int(start) + 1; span <= maxCoalesceSpan {
+		regs := make([]uint16, span)
+		err := d.ReadHoldingRegs(start, regs, opts...)
+		if err != nil {
+			return 0, err
+		}
+		hi, lo = regs[hiAddr-start], regs[loAddr-start]
+	} else {
+		err := d.ReadHoldingRegs(hiAddr, &hi, opts...)
+		if err != nil {
+			return 0, err
+		}
+		err = d.ReadHoldingRegs(loAddr, &lo, opts...)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return uint32(hi)<<16 | uint32(lo), nil
+}
+
 func dataBufSize(data interface{}) (nBytes int, nReg uint16, err error) {
 	n := binary.Size(data)
 	if n == -1 {
@@ -180,43 +369,116 @@ func ParseAddr(addrStr string) (addr uint16, err error) {
 }
 
 func ParseModiconNum(d modbus.StdRegisterFuncs, value string) (addr uint16, f ReadFunc, err error) {
-	value, offset, err := parseOffset(value)
+	var ref ModiconRef
+	err = ref.Parse(value)
 	if err != nil {
 		return 0, nil, err
 	}
+	switch ref.ref {
+	case '3':
+		f = d.ReadInputRegs
+	case '4':
+		f = d.ReadHoldingRegs
+	default:
+		return 0, nil, Error("reference not suppored")
+	}
+	return ref.addr, f, nil
+}
+
+// A ModiconRef is a register reference in Modicon notation,
+// like 40001 or 400001 for the holding register at address 0.
+// The leading digit specifies the object type: 0 for coils,
+// 1 for discrete inputs, 3 for input registers, and 4 for
+// holding registers.
+type ModiconRef struct {
+	ref  byte
+	addr uint16
+	wide bool
+}
+
+// NewModiconRef returns a reference for the object read
+// by the function fn at the specified address.
+func NewModiconRef(fn modbus.FuncCode, addr uint16) (ModiconRef, error) {
+	for ref, f := range modiconFuncs {
+		if f == fn {
+			return ModiconRef{ref: ref, addr: addr, wide: addr >= 9999}, nil
+		}
+	}
+	return ModiconRef{}, Error("function not supported by Modicon references")
+}
+
+var modiconFuncs = map[byte]modbus.FuncCode{
+	'0': modbus.FnReadCoils,
+	'1': modbus.FnReadDiscreteInputs,
+	'3': modbus.FnReadInput,
+	'4': modbus.FnReadHolding,
+}
+
+// Parse parses a reference consisting of the reference digit
+// followed by a four or five digit, 1-based number, optionally
+// followed by an offset like "+2". The offset is added to
+// the address, and is not kept separately, so String returns
+// the reference of the resulting object, e.g. 40003 for "40001+2".
+func (r *ModiconRef) Parse(value string) error {
+	value, offset, err := parseOffset(value)
+	if err != nil {
+		return err
+	}
 	if len(value) == 0 {
-		return 0, nil, Error("empty register number")
+		return Error("empty register number")
 	}
 
 	// decode reference
 	switch value[0] {
-	case '3':
-		f = d.ReadInputRegs
-	case '4':
-		f = d.ReadHoldingRegs
+	case '0', '1', '3', '4':
 	case ' ', '\t':
-		return 0, nil, Error("initial white-space not allowed")
+		return Error("initial white-space not allowed")
 	default:
-		return 0, nil, Error("reference not suppored")
+		return Error("reference not suppored")
 	}
+	ref := value[0]
 
 	value = value[1:]
 	u64, err := strconv.ParseUint(value, 10, 32)
 	if err != nil {
-		return 0, nil, err
+		return err
 	}
 	if u64 == 0 {
-		return 0, nil, Error("0 is not a valid register number")
+		return Error("0 is not a valid register number")
 	}
 	u64 -= 1
 	switch len(value) {
 	default:
-		return 0, nil, Error("invalid number of digits")
+		return Error("invalid number of digits")
 	case 5:
 		if u64 > 0xFFFF {
-			return 0, nil, Error("number exceeds address range")
+			return Error("number exceeds address range")
 		}
 	case 4:
 	}
-	return uint16(u64) + uint16(offset), f, nil
+	r.ref = ref
+	r.addr = uint16(u64) + uint16(offset)
+	r.wide = len(value) == 5 || r.addr >= 9999
+	return nil
+}
+
+// String returns the reference in Modicon notation. A five digit
+// number is used if the reference has been parsed from such a
+// number, or if the address does not fit into four digits.
+func (r ModiconRef) String() string {
+	format := "%c%04d"
+	if r.wide || r.addr >= 9999 {
+		format = "%c%05d"
+	}
+	return fmt.Sprintf(format, r.ref, int(r.addr)+1)
+}
+
+// Func returns the function code used to read the referenced object.
+func (r ModiconRef) Func() modbus.FuncCode {
+	return modiconFuncs[r.ref]
+}
+
+// Addr returns the 0-based address of the referenced object.
+func (r ModiconRef) Addr() uint16 {
+	return r.addr
 }
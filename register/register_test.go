@@ -0,0 +1,286 @@
+package register_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/knieriem/modbus"
+	"github.com/knieriem/modbus/internal/mocknet"
+	"github.com/knieriem/modbus/register"
+)
+
+// A fixedRespDevice responds to each request with the data part of pdu.
+type fixedRespDevice struct {
+	pdu []byte
+}
+
+func (d *fixedRespDevice) Request(_ uint8, _ modbus.Request, resp modbus.Response, _ ...modbus.ReqOption) error {
+	return resp.Decode(d.pdu[1:])
+}
+
+func TestByteCountIsRegCount(t *testing.T) {
+	regCountResp := &fixedRespDevice{pdu: []byte{3, 2, 0x12, 0x34, 0x56, 0x78}}
+	var v [2]uint16
+
+	d := register.NewDevice(regCountResp, register.WithByteCountIsRegCount())
+	err := d.ReadHoldingRegs(0, v[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != [2]uint16{0x1234, 0x5678} {
+		t.Errorf("got %#x", v)
+	}
+
+	// without the option, the response is rejected
+	d = register.NewDevice(regCountResp)
+	err = d.ReadHoldingRegs(0, v[:])
+	var lm *modbus.LengthFieldMismatchError
+	if !errors.As(err, &lm) {
+		t.Fatalf("got %v, want a LengthFieldMismatchError", err)
+	}
+	if lm.Field != 2 || lm.ExpectedLen != 2 || lm.DataLen != 4 {
+		t.Errorf("got %+v", lm)
+	}
+
+	// a conforming response is rejected with the option set,
+	// the error reporting the expected byte count
+	d = register.NewDevice(&fixedRespDevice{pdu: []byte{3, 4, 0x12, 0x34, 0x56, 0x78}}, register.WithByteCountIsRegCount())
+	err = d.ReadHoldingRegs(0, v[:])
+	if !errors.As(err, &lm) {
+		t.Fatalf("got %v, want a LengthFieldMismatchError", err)
+	}
+	if lm.Field != 4 || lm.ExpectedLen != 8 || lm.DataLen != 4 {
+		t.Errorf("got %+v", lm)
+	}
+	if !modbus.MsgInvalid(err) {
+		t.Error("mismatch not classified as invalid reply")
+	}
+	want := "modbus: length field value (4, i.e. 8 bytes) and actual data length inconsistent (4)"
+	if s := err.Error(); s != want {
+		t.Errorf("got %q, want %q", s, want)
+	}
+}
+
+func TestWithoutByteCount(t *testing.T) {
+	noCountResp := &fixedRespDevice{pdu: []byte{3, 0x12, 0x34, 0x56, 0x78}}
+	var v [2]uint16
+
+	d := register.NewDevice(noCountResp, register.WithoutByteCount())
+	err := d.ReadHoldingRegs(0, v[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != [2]uint16{0x1234, 0x5678} {
+		t.Errorf("got %#x", v)
+	}
+
+	// the data size must match the destination buffer
+	var v3 [3]uint16
+	err = d.ReadHoldingRegs(0, v3[:])
+	if !modbus.MsgInvalid(err) {
+		t.Errorf("short response: got %v, want an invalid length error", err)
+	}
+
+	// without the option, the first data byte is taken as byte count
+	d = register.NewDevice(noCountResp)
+	err = d.ReadHoldingRegs(0, v[:])
+	var lm *modbus.LengthFieldMismatchError
+	if !errors.As(err, &lm) {
+		t.Fatalf("got %v, want a LengthFieldMismatchError", err)
+	}
+}
+
+func TestAddrRange(t *testing.T) {
+	for _, tc := range []struct {
+		start, count uint16
+		first, last  uint16
+		err          error
+	}{
+		{0, 1, 0, 0, nil},
+		{0, 0xFFFF, 0, 0xFFFE, nil},
+		{1, 0xFFFF, 1, 0xFFFF, nil},
+		{0xFFFF, 1, 0xFFFF, 0xFFFF, nil},
+		{0xFFFE, 2, 0xFFFE, 0xFFFF, nil},
+		{0xFFFF, 2, 0, 0, register.ErrAddrOverflow},
+		{0xFFFE, 3, 0, 0, register.ErrAddrOverflow},
+		{2, 0xFFFF, 0, 0, register.ErrAddrOverflow},
+		{0, 0, 0, 0, register.ErrEmptyAddrRange},
+		{0xFFFF, 0, 0, 0, register.ErrEmptyAddrRange},
+	} {
+		first, last, err := register.AddrRange(tc.start, tc.count)
+		if err != tc.err || first != tc.first || last != tc.last {
+			t.Errorf("AddrRange(%#x, %#x) = %#x, %#x, %v; want %#x, %#x, %v",
+				tc.start, tc.count, first, last, err, tc.first, tc.last, tc.err)
+		}
+	}
+}
+
+func TestReadRegsAddrOverflow(t *testing.T) {
+	d := register.NewDevice(&fixedRespDevice{pdu: []byte{3, 4, 0, 1, 0, 2}})
+	var v [2]uint16
+	err := d.ReadHoldingRegs(0xFFFE, v[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = d.ReadHoldingRegs(0xFFFF, v[:])
+	if err != register.ErrAddrOverflow {
+		t.Errorf("got %v, want ErrAddrOverflow", err)
+	}
+}
+
+// clampingDevice stores values written to single registers,
+// limited to 999, and echoes the value actually stored.
+func clampingDevice(_ context.Context, req []byte, _ *modbus.ExpectedRespLenSpec) ([]byte, error) {
+	if req[1] != byte(modbus.FnWriteSingleReg) || len(req) != 6 {
+		return []byte{req[0], req[1] | 0x80, byte(modbus.XIllegalFunc)}, nil
+	}
+	resp := append([]byte(nil), req...)
+	if v := modbus.ByteOrder.Uint16(req[4:]); v > 999 {
+		modbus.ByteOrder.PutUint16(resp[4:], 999)
+	}
+	return resp, nil
+}
+
+func TestWriteRegEcho(t *testing.T) {
+	d := register.NewDevice(mocknet.Device(modbus.NewNetwork(mocknet.New(clampingDevice)), 1))
+	for _, tc := range []struct {
+		written, stored uint16
+	}{
+		{500, 500},
+		{999, 999},
+		{1000, 999},
+	} {
+		v, err := d.WriteRegEcho(7, tc.written)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v != tc.stored {
+			t.Errorf("wrote %d: got %d, want %d", tc.written, v, tc.stored)
+		}
+	}
+
+	// the address of the echo is verified
+	wrongAddr := func(ctx context.Context, req []byte, ls *modbus.ExpectedRespLenSpec) ([]byte, error) {
+		resp, err := clampingDevice(ctx, req, ls)
+		resp[3]++
+		return resp, err
+	}
+	d = register.NewDevice(mocknet.Device(modbus.NewNetwork(mocknet.New(wrongAddr)), 1))
+	_, err := d.WriteRegEcho(7, uint16(1))
+	if _, ok := err.(register.Error); !ok {
+		t.Errorf("got %v, want a register.Error", err)
+	}
+}
+
+func TestModiconRef(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		fn   modbus.FuncCode
+		addr uint16
+		out  string
+	}{
+		{"40001", modbus.FnReadHolding, 0, "40001"},
+		{"49999", modbus.FnReadHolding, 9998, "49999"},
+		{"400001", modbus.FnReadHolding, 0, "400001"},
+		{"465536", modbus.FnReadHolding, 0xFFFF, "465536"},
+		{"30100", modbus.FnReadInput, 99, "30100"},
+		{"300100", modbus.FnReadInput, 99, "300100"},
+		{"40001+2", modbus.FnReadHolding, 2, "40003"},
+		{"30010-1", modbus.FnReadInput, 8, "30009"},
+	} {
+		var ref register.ModiconRef
+		err := ref.Parse(tc.in)
+		if err != nil {
+			t.Errorf("%s: %v", tc.in, err)
+			continue
+		}
+		if ref.Func() != tc.fn || ref.Addr() != tc.addr {
+			t.Errorf("%s: got fn %v, addr %d; want %v, %d", tc.in, ref.Func(), ref.Addr(), tc.fn, tc.addr)
+		}
+		if s := ref.String(); s != tc.out {
+			t.Errorf("%s: String returned %s, want %s", tc.in, s, tc.out)
+		}
+	}
+	for _, in := range []string{"", "40000", "20001", "4001", "465537", " 40001"} {
+		var ref register.ModiconRef
+		if err := ref.Parse(in); err == nil {
+			t.Errorf("%q accepted", in)
+		}
+	}
+}
+
+func TestRespLen(t *testing.T) {
+	if n := register.ReadRespLen(2 * 3); n != 8 {
+		t.Errorf("ReadRespLen, 3 registers: got %d, want 8", n)
+	}
+	if n := register.ReadRespLen(2 * 125); n != 252 {
+		t.Errorf("ReadRespLen, 125 registers: got %d, want 252", n)
+	}
+	if n := register.WriteRespLen(); n != 5 {
+		t.Errorf("WriteRespLen: got %d, want 5", n)
+	}
+
+	// the lengths are passed to the network for the respective function codes
+	nc := mocknet.New(func(_ context.Context, req []byte, _ *modbus.ExpectedRespLenSpec) ([]byte, error) {
+		switch modbus.FuncCode(req[1]) {
+		case modbus.FnReadHolding:
+			return []byte{req[0], req[1], 6, 0, 1, 0, 2, 0, 3}, nil
+		case modbus.FnWriteSingleReg:
+			return req, nil
+		}
+		return append([]byte(nil), req[:6]...), nil
+	})
+	d := register.NewDevice(mocknet.Device(modbus.NewNetwork(nc), 1))
+	var regs [3]uint16
+	if err := d.ReadHoldingRegs(0, regs[:]); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.WriteReg(0, uint16(1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.WriteRegs(0, regs[:]); err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []int{8, 5, 5} {
+		ls := nc.Specs[i]
+		if ls == nil || len(ls.ValidLen) != 1 || ls.ValidLen[0] != want {
+			t.Errorf("request %d: got length spec %+v, want %d", i, ls, want)
+		}
+	}
+}
+
+func TestReadSplitUint32(t *testing.T) {
+	regs := map[uint16]uint16{0x00: 0x1234, 0x03: 0xABCD, 0x10: 0x5678}
+	nc := mocknet.New(func(_ context.Context, req []byte, _ *modbus.ExpectedRespLenSpec) ([]byte, error) {
+		start, n := modbus.ByteOrder.Uint16(req[2:]), modbus.ByteOrder.Uint16(req[4:])
+		resp := []byte{req[0], req[1], byte(2 * n)}
+		for i := uint16(0); i < n; i++ {
+			resp = append(resp, byte(regs[start+i]>>8), byte(regs[start+i]))
+		}
+		return resp, nil
+	})
+	d := register.NewDevice(mocknet.Device(modbus.NewNetwork(nc), 1))
+
+	for _, tc := range []struct {
+		hi, lo uint16
+		want   uint32
+		nReq   int
+	}{
+		{0x00, 0x10, 0x12345678, 2},
+		{0x10, 0x00, 0x56781234, 2},
+		{0x00, 0x03, 0x1234ABCD, 1},
+	} {
+		nc.Sent = nil
+		v, err := register.ReadSplitUint32(d, tc.hi, tc.lo)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v != tc.want {
+			t.Errorf("hi %#x, lo %#x: got %#x, want %#x", tc.hi, tc.lo, v, tc.want)
+		}
+		if n := len(nc.Sent); n != tc.nReq {
+			t.Errorf("hi %#x, lo %#x: %d requests, want %d", tc.hi, tc.lo, n, tc.nReq)
+		}
+	}
+}
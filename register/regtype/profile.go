@@ -0,0 +1,186 @@
+package regtype
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/knieriem/modbus"
+	"github.com/knieriem/modbus/register"
+)
+
+// A Profile describes the registers of a device. Since its fields
+// are exported, it may be decoded from JSON, as done by LoadProfile,
+// but also from other formats like tidata.
+type Profile struct {
+	Registers []ProfileEntry
+}
+
+// A ProfileEntry describes a named register value.
+// Fn is the function code used for reading, either 3 (holding
+// registers, the default if zero) or 4 (input registers).
+// Type is a type spec as accepted by ParseTypeSpec.
+type ProfileEntry struct {
+	Name string
+	Fn   uint8
+	Addr uint16
+	Type string
+}
+
+// LoadProfile reads a Profile in JSON format from r,
+// like
+//
+//	{"Registers": [
+//		{"Name": "temp", "Fn": 4, "Addr": 0, "Type": "i/10[°C]"},
+//		{"Name": "serial", "Addr": 100, "Type": "4c"}
+//	]}
+//
+// and returns the corresponding Map.
+func LoadProfile(r io.Reader) (*Map, error) {
+	var p Profile
+	err := json.NewDecoder(r).Decode(&p)
+	if err != nil {
+		return nil, err
+	}
+	return p.Map()
+}
+
+// A Map is a validated Profile, ready to read
+// the described registers from a device.
+type Map struct {
+	fields []mapField
+}
+
+type mapField struct {
+	name string
+	fn   modbus.FuncCode
+	addr uint16
+	spec *TypeSpec
+}
+
+func (f *mapField) end() int {
+	return int(f.addr) + f.spec.NReg()
+}
+
+// maxReadRegs is the maximum number of registers
+// that can be read using a single request.
+const maxReadRegs = 125
+
+// Map validates the profile, and returns a Map.
+// Names must be unique and non-empty, type specs valid,
+// and registers must fit into the address space.
+func (p *Profile) Map() (*Map, error) {
+	m := new(Map)
+	seen := make(map[string]bool, len(p.Registers))
+	for i := range p.Registers {
+		e := &p.Registers[i]
+		if e.Name == "" {
+			return nil, fmt.Errorf("profile entry %d: missing name", i+1)
+		}
+		if seen[e.Name] {
+			return nil, fmt.Errorf("profile entry %q: duplicate name", e.Name)
+		}
+		seen[e.Name] = true
+		f := mapField{name: e.Name, addr: e.Addr}
+		switch modbus.FuncCode(e.Fn) {
+		case 0, modbus.FnReadHolding:
+			f.fn = modbus.FnReadHolding
+		case modbus.FnReadInput:
+			f.fn = modbus.FnReadInput
+		default:
+			return nil, fmt.Errorf("profile entry %q: unsupported function code %d", e.Name, e.Fn)
+		}
+		ts, err := ParseTypeSpec(e.Type)
+		if err != nil {
+			return nil, fmt.Errorf("profile entry %q: %v", e.Name, err)
+		}
+		n := ts.NReg()
+		if n > maxReadRegs {
+			return nil, fmt.Errorf("profile entry %q: too many registers", e.Name)
+		}
+		_, _, err = register.AddrRange(e.Addr, uint16(n))
+		if err != nil {
+			return nil, fmt.Errorf("profile entry %q: %v", e.Name, err)
+		}
+		f.spec = ts
+		m.fields = append(m.fields, f)
+	}
+	return m, nil
+}
+
+// Names returns the names of the entries, in profile order.
+func (m *Map) Names() []string {
+	names := make([]string, len(m.fields))
+	for i := range m.fields {
+		names[i] = m.fields[i].name
+	}
+	return names
+}
+
+// A NamedValue holds the values decoded for a profile entry.
+// Values contains more than one element if the type spec
+// has a count greater than one.
+type NamedValue struct {
+	Name   string
+	Values []Value
+}
+
+// Read reads the registers described by the map from d, and
+// returns the decoded values in profile order. Adjacent or
+// overlapping registers of the same type are read using
+// a single request.
+func (m *Map) Read(d *register.Device, opts ...modbus.ReqOption) ([]NamedValue, error) {
+	idx := make([]int, len(m.fields))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(i, j int) bool {
+		fi, fj := &m.fields[idx[i]], &m.fields[idx[j]]
+		if fi.fn != fj.fn {
+			return fi.fn < fj.fn
+		}
+		return fi.addr < fj.addr
+	})
+
+	list := make([]NamedValue, len(m.fields))
+	for len(idx) != 0 {
+		first := &m.fields[idx[0]]
+		start, end := int(first.addr), first.end()
+		n := 1
+		for ; n < len(idx); n++ {
+			f := &m.fields[idx[n]]
+			if f.fn != first.fn || int(f.addr) > end {
+				break
+			}
+			e := end
+			if f.end() > e {
+				e = f.end()
+			}
+			if e-start > maxReadRegs {
+				break
+			}
+			end = e
+		}
+		read := d.ReadHoldingRegs
+		if first.fn == modbus.FnReadInput {
+			read = d.ReadInputRegs
+		}
+		buf := make([]byte, 2*(end-start))
+		err := read(uint16(start), buf, opts...)
+		if err != nil {
+			return nil, err
+		}
+		for _, i := range idx[:n] {
+			f := &m.fields[i]
+			off := 2 * (int(f.addr) - start)
+			vlist, err := DecodeRaw(buf[off:], []*TypeSpec{f.spec})
+			if err != nil {
+				return nil, err
+			}
+			list[i] = NamedValue{Name: f.name, Values: vlist}
+		}
+		idx = idx[n:]
+	}
+	return list, nil
+}
@@ -0,0 +1,82 @@
+package regtype
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/knieriem/modbus/internal/mocknet"
+	"github.com/knieriem/modbus/register"
+	"github.com/knieriem/modbus/server"
+)
+
+func TestLoadProfile(t *testing.T) {
+	f, err := os.Open("testdata/profile.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	m, err := LoadProfile(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := strings.Join(m.Names(), " "); names != "temp humidity serial hours" {
+		t.Errorf("names: %s", names)
+	}
+
+	store := server.NewMapStore()
+	store.Input[0] = 0xFF29 // -215
+	store.Input[1] = 456
+	for i, s := range []string{"AB", "12", "CD", "34"} {
+		store.Holding[100+uint16(i)] = uint16(s[0])<<8 | uint16(s[1])
+	}
+	store.Holding[104] = 0x0001
+	store.Holding[105] = 0x2345
+	h := server.NewHandler(store)
+	d := register.NewDevice(mocknet.Device(h, 1))
+
+	list, err := m.Read(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []struct {
+		name, value, unit string
+	}{
+		{"temp", "-21.5", "°C"},
+		{"humidity", "45.6", "%"},
+		{"serial", `"AB12CD34"`, ""},
+		{"hours", "74565", "h"},
+	} {
+		nv := list[i]
+		if nv.Name != want.name || len(nv.Values) != 1 {
+			t.Errorf("entry %d: got %+v", i, nv)
+			continue
+		}
+		v := nv.Values[0]
+		if s := v.String(); s != want.value {
+			t.Errorf("%s: got %q, want %q", nv.Name, s, want.value)
+		}
+		if u := v.Unit(); u != want.unit {
+			t.Errorf("%s: got unit %q, want %q", nv.Name, u, want.unit)
+		}
+	}
+}
+
+func TestProfileInvalid(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		profile string
+	}{
+		{"missing name", `{"Registers": [{"Addr": 1, "Type": "u"}]}`},
+		{"duplicate name", `{"Registers": [{"Name": "a", "Type": "u"}, {"Name": "a", "Addr": 1, "Type": "u"}]}`},
+		{"function code", `{"Registers": [{"Name": "a", "Fn": 6, "Type": "u"}]}`},
+		{"type spec", `{"Registers": [{"Name": "a", "Type": "8s"}]}`},
+		{"address range", `{"Registers": [{"Name": "a", "Addr": 65535, "Type": "u32"}]}`},
+		{"too long", `{"Registers": [{"Name": "a", "Type": "130u"}]}`},
+	} {
+		_, err := LoadProfile(strings.NewReader(tc.profile))
+		if err == nil {
+			t.Errorf("%s: no error", tc.name)
+		}
+	}
+}
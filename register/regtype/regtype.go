@@ -5,6 +5,7 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"math"
 	"reflect"
 	"strconv"
 	"strings"
@@ -38,6 +39,15 @@ type def struct {
 	parse     func(string) (baseValue, error)
 	size      int
 	fmt       string
+
+	// float tells that the base type is a floating point type.
+	float bool
+
+	// bytePair tells that each register contains two 8-bit
+	// values, high byte first, or, if lowByteFirst is set,
+	// low byte first.
+	bytePair     bool
+	lowByteFirst bool
 }
 
 type baseValue interface {
@@ -48,36 +58,98 @@ type baseValue interface {
 type Value struct {
 	baseValue
 	byteOrder binary.ByteOrder
+	unit      string
 }
 
 func (v Value) String() string {
 	return v.Format()
 }
 
+// Unit returns the unit of the value, as specified in its TypeSpec.
+func (v Value) Unit() string {
+	return v.unit
+}
+
+// FormatUnit returns the formatted value, followed
+// by its unit, separated by a space, if present.
+func (v Value) FormatUnit() string {
+	if v.unit == "" {
+		return v.Format()
+	}
+	return v.Format() + " " + v.unit
+}
+
 func (v Value) Err() error {
 	return inbandErr(v.baseValue)
 }
 
+// Native returns the value as a plain Go type, like uint16, int32,
+// float64, or string, so that a consumer does not need to know
+// about the types defined in this package. Dates are returned as
+// time.Time values in UTC. For ignored values, and values carrying
+// an error, nil is returned.
+func (v Value) Native() interface{} {
+	if v.Err() != nil {
+		return nil
+	}
+	switch x := v.Value().(type) {
+	case Ignored:
+		return nil
+	case Uint8:
+		return uint8(x)
+	case Int8:
+		return int8(x)
+	case Uint16:
+		return uint16(x)
+	case Uint32:
+		return uint32(x)
+	case Uint64:
+		return uint64(x)
+	case Int16:
+		return int16(x)
+	case Int32:
+		return int32(x)
+	case Int64:
+		return int64(x)
+	case Float32:
+		return float32(x)
+	case Float64:
+		return float64(x)
+	case String:
+		return register.DecodeString(x, register.TrimRightSpace)
+	case StringBS:
+		return register.DecodeString(x, register.TrimRightSpace)
+	case *Date:
+		return x.In(time.UTC)
+	default:
+		return x
+	}
+}
+
 var types = map[string]*def{
 	"f": {
 		makeSlice: makeFloat32,
 		parse:     newFloat32,
 		size:      2,
+		float:     true,
 	},
 	"f16": {
 		makeSlice: makeFloat16,
 		parse:     newFloat16,
 		size:      1,
+		float:     true,
 	},
 	"f32": {
 		makeSlice: makeFloat32,
 		parse:     newFloat32,
 		size:      2,
+		float:     true,
 	},
 	"f64": {
 		makeSlice: makeFloat64,
 		parse:     newFloat64,
 		size:      4,
+		float:     true,
 	},
 	"u": {
 		makeSlice: makeUint16,
@@ -109,6 +181,32 @@ var types = map[string]*def{
 		parse:     newInt64,
 		size:      4,
 	},
+	"u8": {
+		makeSlice: makeUint8,
+		parse:     newUint8,
+		size:      1,
+		bytePair:  true,
+	},
+	"u8le": {
+		makeSlice:    makeUint8,
+		parse:        newUint8,
+		size:         1,
+		bytePair:     true,
+		lowByteFirst: true,
+	},
+	"i8": {
+		makeSlice: makeInt8,
+		parse:     newInt8,
+		size:      1,
+		bytePair:  true,
+	},
+	"i8le": {
+		makeSlice:    makeInt8,
+		parse:        newInt8,
+		size:         1,
+		bytePair:     true,
+		lowByteFirst: true,
+	},
 	"x": {
 		makeSlice: makeUint16,
 		fmt:       "%x",
@@ -163,6 +261,34 @@ func formatUint(u uint64) string {
 	return strconv.FormatUint(u, 10)
 }
 
+type Uint8 uint8
+
+func (u Uint8) Format() string {
+	return formatUint(uint64(u))
+}
+
+func (u Uint8) Value() interface{} {
+	return u
+}
+
+func (u Uint8) float() float64 {
+	return float64(u)
+}
+
+// makeUint8 returns a slice of n registers, each containing two values.
+func makeUint8(n int) interface{} {
+	return make([][2]Uint8, n)
+}
+
+func newUint8(s string) (v baseValue, err error) {
+	n, err := strconv.ParseUint(s, 0, 8)
+	if err != nil {
+		return
+	}
+	v = Uint8(n)
+	return
+}
+
 type Uint16 uint16
 
 func (u Uint16) Format() string {
@@ -244,6 +370,34 @@ func newUint64(s string) (v baseValue, err error) {
 	return
 }
 
+type Int8 int8
+
+func (i Int8) Format() string {
+	return strconv.FormatInt(int64(i), 10)
+}
+
+func (i Int8) Value() interface{} {
+	return i
+}
+
+func (i Int8) float() float64 {
+	return float64(i)
+}
+
+// makeInt8 returns a slice of n registers, each containing two values.
+func makeInt8(n int) interface{} {
+	return make([][2]Int8, n)
+}
+
+func newInt8(s string) (v baseValue, err error) {
+	n, err := strconv.ParseInt(s, 0, 8)
+	if err != nil {
+		return
+	}
+	v = Int8(n)
+	return
+}
+
 type Int16 int16
 
 func (i Int16) Format() string {
@@ -541,6 +695,61 @@ type divValue struct {
 	prec int
 }
 
+// offsetValue is an unsigned value stored as offset binary,
+// also known as excess-K, like in case of a 16-bit register
+// containing a signed value plus 32768. It is specified using
+// a modifier consisting of "offset" and the bias, like "u.offset32768".
+// The modifier is accepted for unsigned types only.
+type offsetValue struct {
+	baseValue
+	bias int64
+}
+
+func parseOffsetMod(mod string) (int64, bool) {
+	if !strings.HasPrefix(mod, "offset") {
+		return 0, false
+	}
+	k, err := strconv.ParseInt(mod[len("offset"):], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return k, true
+}
+
+// addOffset converts the physical value s into its
+// offset binary representation.
+func addOffset(s string, bias int64) (string, error) {
+	i, err := strconv.ParseInt(s, 0, 64)
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatInt(i+bias, 10), nil
+}
+
+func (v *offsetValue) Value() interface{} {
+	var u uint64
+	switch raw := v.baseValue.Value().(type) {
+	case Uint8:
+		u = uint64(raw)
+	case Uint16:
+		u = uint64(raw)
+	case Uint32:
+		u = uint64(raw)
+	case Uint64:
+		u = uint64(raw)
+	default:
+		return raw
+	}
+	return Int64(int64(u) - v.bias)
+}
+
+func (v *offsetValue) Format() string {
+	if i, ok := v.Value().(Int64); ok {
+		return i.Format()
+	}
+	return v.baseValue.Format()
+}
+
 type floater interface {
 	float() float64
 }
@@ -611,15 +820,40 @@ func parseValueSpec(dest []Value, s string) (vlist []Value, nRegs int, err error
 		args = []string{s}
 	} else {
 		args = strings.Split(strings.TrimSpace(s), " ")
+		nArgs := len(args)
+		if d.bytePair {
+			if nArgs%2 != 0 {
+				err = errors.New("number of 8-bit values must be even")
+				return
+			}
+			nArgs /= 2
+			if d.lowByteFirst {
+				for i := 0; i < len(args); i += 2 {
+					args[i], args[i+1] = args[i+1], args[i]
+				}
+			}
+		}
 		if count == 0 {
-			count = len(args)
-		} else if count != len(args) {
+			count = nArgs
+		} else if count != nArgs {
 			err = errors.New("number of values doesn't match the specified count")
 			return
 		}
 	}
 	vlist = dest
 	for _, f := range args {
+		if ts.div != 0 {
+			f, err = scaleValue(f, ts.div, !d.float)
+			if err != nil {
+				return
+			}
+		}
+		if ts.offset != 0 {
+			f, err = addOffset(f, ts.offset)
+			if err != nil {
+				return
+			}
+		}
 		v, err1 := d.parse(f)
 		if err1 != nil {
 			err = err1
@@ -631,6 +865,22 @@ func parseValueSpec(dest []Value, s string) (vlist []Value, nRegs int, err error
 	return
 }
 
+// scaleValue converts the physical value s of a type with
+// a divisor into the raw value, which is rounded to the nearest
+// integer, if round is set, i.e. in case of integer base types.
+// A range check is left to the type's parse function.
+func scaleValue(s string, div uint, round bool) (string, error) {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return "", err
+	}
+	f *= float64(div)
+	if round {
+		f = math.Round(f)
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64), nil
+}
+
 type TypeSpec struct {
 	*def
 	byteOrder binary.ByteOrder
@@ -641,6 +891,14 @@ type TypeSpec struct {
 	name      string
 	mf        ModifierFunc
 	procOpts  string
+	unit      string
+	offset    int64
+}
+
+// Unit returns the unit specified in brackets
+// at the end of a type spec, like in "i/10[°C]".
+func (ts *TypeSpec) Unit() string {
+	return ts.unit
 }
 
 func (ts *TypeSpec) NReg() int {
@@ -694,6 +952,14 @@ func scanTypeSpec(s string) (*TypeSpec, error) {
 		}
 		ts.n = int(n64)
 	}
+	if strings.HasSuffix(typeName, "]") {
+		i := strings.LastIndexByte(typeName, '[')
+		if i == -1 {
+			return nil, errors.New("missing '['")
+		}
+		ts.unit = typeName[i+1 : len(typeName)-1]
+		typeName = typeName[:i]
+	}
 	if i := strings.LastIndexByte(typeName, '/'); i != -1 {
 		divstr := typeName[i:]
 		typeName = typeName[:i]
@@ -728,11 +994,13 @@ func scanTypeSpec(s string) (*TypeSpec, error) {
 	}
 	if i := strings.IndexByte(typeName, '.'); i != -1 {
 		mod := typeName[i+1:]
-		mf, ok := modMap[mod]
-		if !ok {
+		if mf, ok := modMap[mod]; ok {
+			ts.mf = mf
+		} else if k, ok := parseOffsetMod(mod); ok {
+			ts.offset = k
+		} else {
 			return ts, errors.New("unknown modifier: " + strconv.Quote(mod))
 		}
-		ts.mf = mf
 		typeName = typeName[:i]
 	}
 	if i := strings.IndexByte(typeName, ','); i != -1 {
@@ -761,10 +1029,23 @@ func scanTypeSpec(s string) (*TypeSpec, error) {
 			}
 		}
 	}
+	if ts.offset != 0 && !isUnsigned(typeName) {
+		return nil, errors.New("offset modifier requires an unsigned type")
+	}
 	ts.name = typeName
 	return ts, nil
 }
 
+// isUnsigned reports whether the named type is an unsigned
+// integer type, which is the default, if the name is empty.
+func isUnsigned(typeName string) bool {
+	switch typeName {
+	case "", "u", "u32", "u64", "u8", "u8le", "x", "x32":
+		return true
+	}
+	return false
+}
+
 func ParseValues(values []string) (vlist []Value, nRegs int, err error) {
 	var bracedExpr string
 
@@ -841,6 +1122,11 @@ func Encode(b []byte, vlist []Value, opts ...EncodingOption) (err error) {
 	return
 }
 
+// Decode decodes raw register data, i.e. the bytes following
+// the byte count of a read registers response, as returned by
+// ReadHoldingRegs or ReadInputRegs into a byte slice. It does not
+// expect a PDU header; see DecodePDU for decoding a complete PDU.
+// In case b is too short, nil is returned.
 func Decode(b []byte, specs []*TypeSpec, opts ...EncodingOption) []Value {
 	e := setupEncOptions(opts)
 
@@ -869,29 +1155,135 @@ func Decode(b []byte, specs []*TypeSpec, opts ...EncodingOption) []Value {
 					bv = &procValue{opts: ts.procOpts, baseValue: bv}
 				}
 			}
-			vlist = append(vlist, Value{baseValue: bv})
+			vlist = append(vlist, Value{baseValue: bv, unit: ts.unit})
 			continue
 		}
 		v := reflect.ValueOf(sl)
+		var elemVals [2]baseValue
 		for i := 0; i < ts.n; i++ {
-			val := v.Index(i).Interface().(baseValue)
-			if mf := ts.mf; mf != nil {
-				val = ts.mf(val)
+			vals := elemVals[:1]
+			if ts.bytePair {
+				vals = elemVals[:2]
+				vals[0] = v.Index(i).Index(0).Interface().(baseValue)
+				vals[1] = v.Index(i).Index(1).Interface().(baseValue)
+				if ts.lowByteFirst {
+					vals[0], vals[1] = vals[1], vals[0]
+				}
+			} else {
+				vals[0] = elemValue(v.Index(i))
 			}
-			if inbandErr(val) == nil {
-				if ts.div != 0 {
-					val = &divValue{div: ts.div, baseValue: val, prec: ts.divDigits}
+			for _, val := range vals {
+				if mf := ts.mf; mf != nil {
+					val = ts.mf(val)
 				}
-				if ts.fmt != "" {
-					val = &fmtValue{fmt: ts.fmt, baseValue: val}
+				if inbandErr(val) == nil {
+					if ts.offset != 0 {
+						val = &offsetValue{bias: ts.offset, baseValue: val}
+					}
+					if ts.div != 0 {
+						val = &divValue{div: ts.div, baseValue: val, prec: ts.divDigits}
+					}
+					if ts.fmt != "" {
+						val = &fmtValue{fmt: ts.fmt, baseValue: val}
+					}
 				}
+				vlist = append(vlist, Value{baseValue: val, unit: ts.unit})
 			}
-			vlist = append(vlist, Value{baseValue: val})
 		}
 	}
 	return vlist
 }
 
+// elemValue returns the baseValue of a slice element. In case of
+// types implementing baseValue using pointer receivers, like Date,
+// a pointer to the element is returned.
+func elemValue(e reflect.Value) baseValue {
+	if bv, ok := e.Interface().(baseValue); ok {
+		return bv
+	}
+	return e.Addr().Interface().(baseValue)
+}
+
+// A SpecValue is a Value together with the TypeSpec
+// it has been decoded from, and its index within the values
+// produced by that spec.
+type SpecValue struct {
+	Value
+	Spec  *TypeSpec
+	Index int
+}
+
+// DecodeWithSpecs decodes raw register data like DecodeRaw, but
+// returns, for each value, the TypeSpec it originates from. This helps
+// aligning values with labels or column headers, in case a spec
+// with a count greater than one expands to multiple values.
+func DecodeWithSpecs(b []byte, specs []*TypeSpec, opts ...EncodingOption) ([]SpecValue, error) {
+	var list []SpecValue
+	for _, ts := range specs {
+		n := ts.NReg() * 2
+		vlist, err := DecodeRaw(b, []*TypeSpec{ts}, opts...)
+		if err != nil {
+			return nil, err
+		}
+		for i, v := range vlist {
+			list = append(list, SpecValue{Value: v, Spec: ts, Index: i})
+		}
+		b = b[n:]
+	}
+	return list, nil
+}
+
+// DecodePDU decodes the register data contained in the PDU
+// of a read registers response, like one received by
+// ReadHoldingRegs or ReadInputRegs. The PDU is expected
+// to start with the function code, followed by the byte count.
+func DecodePDU(pdu []byte, specs []*TypeSpec, opts ...EncodingOption) ([]Value, error) {
+	if len(pdu) < 2 {
+		return nil, modbus.NewInvalidLen(modbus.MsgContextPDU, len(pdu), 2)
+	}
+	if pdu[0]&modbus.ErrorMask != 0 {
+		return nil, errors.New("PDU is an exception response")
+	}
+	data := pdu[2:]
+	if int(pdu[1]) != len(data) {
+		return nil, modbus.NewLengthFieldMismatch(int(pdu[1]), len(data))
+	}
+	return DecodeRaw(data, specs, opts...)
+}
+
+// DecodeRaw decodes raw register data like Decode, but returns
+// an error if b does not contain enough bytes for all specs.
+// Given a PDU of a read registers response, e.g. 03 04 00 01 00 02,
+// DecodePDU would be applied to the complete PDU,
+// while DecodeRaw expects just the register data 00 01 00 02.
+func DecodeRaw(b []byte, specs []*TypeSpec, opts ...EncodingOption) ([]Value, error) {
+	nBytes := 0
+	for _, ts := range specs {
+		nBytes += ts.NReg() * 2
+	}
+	if len(b) < nBytes {
+		return nil, modbus.NewInvalidLen(modbus.MsgContextData, len(b), nBytes)
+	}
+	return Decode(b, specs, opts...), nil
+}
+
+// ReadAuto reads nRegs registers starting at addr, using the
+// read function f, e.g. the ReadHoldingRegs method of a register.Device,
+// and returns them as unsigned 16-bit values. It is meant for
+// exploring register ranges without having to specify types.
+func ReadAuto(f register.ReadFunc, addr, nRegs uint16, opts ...modbus.ReqOption) ([]Value, error) {
+	regs := make([]Uint16, nRegs)
+	err := f(addr, regs, opts...)
+	if err != nil {
+		return nil, err
+	}
+	vlist := make([]Value, nRegs)
+	for i, u := range regs {
+		vlist[i].baseValue = u
+	}
+	return vlist, nil
+}
+
 func setupEncOptions(opts []EncodingOption) *encOptions {
 	var e encOptions
 
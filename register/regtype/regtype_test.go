@@ -0,0 +1,268 @@
+package regtype
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/knieriem/modbus"
+)
+
+// encodeValues parses the value specs, and returns the encoded registers.
+func encodeValues(t *testing.T, specs ...string) []byte {
+	t.Helper()
+	vlist, nRegs, err := ParseValues(specs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := make([]byte, 2*nRegs)
+	err = Encode(b, vlist)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestEncodeScaled(t *testing.T) {
+	for _, tc := range []struct {
+		spec string
+		want []byte
+	}{
+		// 235, -50
+		{"i/10(23.5 -5.0)", []byte{0x00, 0xEB, 0xFF, 0xCE}},
+		// rounded to the nearest integer
+		{"u/10(1.04 1.05)", []byte{0x00, 0x0A, 0x00, 0x0B}},
+		// 23.55 * 10 = 235.5, not rounded
+		{"f/10(23.55)", []byte{0x43, 0x6B, 0x80, 0x00}},
+	} {
+		if b := encodeValues(t, tc.spec); !bytes.Equal(b, tc.want) {
+			t.Errorf("%s: got % x, want % x", tc.spec, b, tc.want)
+		}
+	}
+}
+
+func TestOffset(t *testing.T) {
+	ts, err := ParseTypeSpec("2u.offset32768")
+	if err != nil {
+		t.Fatal(err)
+	}
+	vlist := Decode([]byte{0x80, 0x00, 0x00, 0x00}, []*TypeSpec{ts})
+	if len(vlist) != 2 {
+		t.Fatalf("got %d values, want 2", len(vlist))
+	}
+	for i, want := range []string{"0", "-32768"} {
+		if s := vlist[i].Format(); s != want {
+			t.Errorf("value %d: got %s, want %s", i, s, want)
+		}
+	}
+	if b := encodeValues(t, "2u.offset32768(0 -32768)"); !bytes.Equal(b, []byte{0x80, 0, 0, 0}) {
+		t.Errorf("encoded: % x", b)
+	}
+
+	for _, spec := range []string{"i.offset100", "i32.offset1", "f.offset1", "c.offset1"} {
+		if _, err := ParseTypeSpec(spec); err == nil {
+			t.Errorf("%s: offset accepted", spec)
+		}
+	}
+}
+
+func TestBytePair(t *testing.T) {
+	for _, tc := range []struct {
+		spec string
+		reg  []byte
+		want [2]string
+	}{
+		{"u8", []byte{0x12, 0x34}, [2]string{"18", "52"}},
+		{"u8le", []byte{0x12, 0x34}, [2]string{"52", "18"}},
+		{"i8", []byte{0xFF, 0x01}, [2]string{"-1", "1"}},
+		{"i8le", []byte{0xFF, 0x01}, [2]string{"1", "-1"}},
+	} {
+		ts, err := ParseTypeSpec(tc.spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := ts.NReg(); n != 1 {
+			t.Errorf("%s: %d registers, want 1", tc.spec, n)
+		}
+		vlist := Decode(tc.reg, []*TypeSpec{ts})
+		if len(vlist) != 2 {
+			t.Errorf("%s: got %d values, want 2", tc.spec, len(vlist))
+			continue
+		}
+		if got := [2]string{vlist[0].Format(), vlist[1].Format()}; got != tc.want {
+			t.Errorf("%s: got %v, want %v", tc.spec, got, tc.want)
+		}
+
+		enc := encodeValues(t, tc.spec+"("+tc.want[0]+" "+tc.want[1]+")")
+		if !bytes.Equal(enc, tc.reg) {
+			t.Errorf("%s: encoded % x, want % x", tc.spec, enc, tc.reg)
+		}
+	}
+
+	for _, spec := range []string{"u8(1 2 3)", "i8le(1)"} {
+		if _, _, err := ParseValues([]string{spec}); err == nil {
+			t.Errorf("%s: odd number of values accepted", spec)
+		}
+	}
+}
+
+func TestNative(t *testing.T) {
+	for _, tc := range []struct {
+		spec string
+		raw  []byte
+		want interface{}
+	}{
+		{"u", []byte{0x12, 0x34}, uint16(0x1234)},
+		{"x", []byte{0x12, 0x34}, uint16(0x1234)},
+		{"i", []byte{0xFF, 0xFE}, int16(-2)},
+		{"u32", []byte{0, 1, 0, 2}, uint32(0x10002)},
+		{"i32", []byte{0xFF, 0xFF, 0xFF, 0xFE}, int32(-2)},
+		{"u64", []byte{0, 0, 0, 0, 0, 0, 0, 3}, uint64(3)},
+		{"i64", []byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFD}, int64(-3)},
+		{"u8", []byte{0x07, 0x00}, uint8(7)},
+		{"i8", []byte{0xF9, 0x00}, int8(-7)},
+		{"f", []byte{0x3F, 0xC0, 0, 0}, float32(1.5)},
+		{"f16", []byte{0x3E, 0x00}, float32(1.5)},
+		{"f64", []byte{0x3F, 0xF8, 0, 0, 0, 0, 0, 0}, float64(1.5)},
+		{"i/10", []byte{0x00, 0xEB}, float64(23.5)},
+		{"u.offset32768", []byte{0x7F, 0xFF}, int64(-1)},
+		{"2c", []byte{'a', 'b', 'c', ' '}, "abc"},
+		{"_", []byte{0x12, 0x34}, nil},
+		{"date", []byte{0x07, 0xE8, 0, 5, 0, 17}, time.Date(2024, 5, 17, 0, 0, 0, 0, time.UTC)},
+	} {
+		ts, err := ParseTypeSpec(tc.spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		vlist := Decode(tc.raw, []*TypeSpec{ts})
+		if len(vlist) == 0 {
+			t.Errorf("%s: no value decoded", tc.spec)
+			continue
+		}
+		if v := vlist[0].Native(); v != tc.want {
+			t.Errorf("%s: got %T %v, want %T %v", tc.spec, v, v, tc.want, tc.want)
+		}
+	}
+}
+
+func TestDecodeWithSpecs(t *testing.T) {
+	specs, _, err := ParseSpecs([]string{"u", "3i", "u32"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw := []byte{
+		0, 1,
+		0, 2, 0, 3, 0xFF, 0xFC,
+		0, 0, 0, 5,
+	}
+	list, err := DecodeWithSpecs(raw, specs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		spec  *TypeSpec
+		index int
+		value string
+	}{
+		{specs[0], 0, "1"},
+		{specs[1], 0, "2"},
+		{specs[1], 1, "3"},
+		{specs[1], 2, "-4"},
+		{specs[2], 0, "5"},
+	}
+	if len(list) != len(want) {
+		t.Fatalf("got %d values, want %d", len(list), len(want))
+	}
+	for i, w := range want {
+		sv := list[i]
+		if sv.Spec != w.spec || sv.Index != w.index || sv.Format() != w.value {
+			t.Errorf("value %d: got spec %p, index %d, %s; want spec %p, index %d, %s",
+				i, sv.Spec, sv.Index, sv.Format(), w.spec, w.index, w.value)
+		}
+	}
+
+	if _, err := DecodeWithSpecs(raw[:len(raw)-1], specs); err == nil {
+		t.Error("short data accepted")
+	}
+}
+
+func TestDecodePDU(t *testing.T) {
+	specs, _, err := ParseSpecs([]string{"2u"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	vlist, err := DecodePDU([]byte{0x03, 0x04, 0, 1, 0, 2}, specs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vlist) != 2 || vlist[0].Format() != "1" || vlist[1].Format() != "2" {
+		t.Errorf("unexpected values: %v", vlist)
+	}
+
+	for _, tc := range []struct {
+		name string
+		pdu  []byte
+		want error
+	}{
+		{"exception", []byte{0x83, 0x02}, nil},
+		{"byte count mismatch", []byte{0x03, 0x06, 0, 1, 0, 2}, &modbus.LengthFieldMismatchError{}},
+		{"short PDU", []byte{0x03}, &modbus.InvalidLenError{}},
+		{"short data", []byte{0x03, 0x02, 0, 1}, &modbus.InvalidLenError{}},
+	} {
+		vlist, err := DecodePDU(tc.pdu, specs)
+		if err == nil {
+			t.Errorf("%s: no error, values: %v", tc.name, vlist)
+			continue
+		}
+		if tc.want != nil && reflect.TypeOf(err) != reflect.TypeOf(tc.want) {
+			t.Errorf("%s: got %T, want %T", tc.name, err, tc.want)
+		}
+	}
+}
+
+func TestDecodeRaw(t *testing.T) {
+	specs, _, err := ParseSpecs([]string{"u", "u32"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DecodeRaw([]byte{0, 1, 0, 0, 0}, specs); err == nil {
+		t.Error("short buffer accepted")
+	}
+	vlist, err := DecodeRaw([]byte{0, 1, 0, 0, 0, 2}, specs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vlist) != 2 || vlist[1].Format() != "2" {
+		t.Errorf("unexpected values: %v", vlist)
+	}
+}
+
+func TestUnit(t *testing.T) {
+	ts, err := ParseTypeSpec("2i/10[°C]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u := ts.Unit(); u != "°C" {
+		t.Errorf("spec unit: %q", u)
+	}
+	vlist := Decode([]byte{0x00, 0xEB, 0xFF, 0xCE}, []*TypeSpec{ts})
+	for i, want := range []string{"23.5 °C", "-5.0 °C"} {
+		v := vlist[i]
+		if v.Unit() != "°C" {
+			t.Errorf("value %d: unit %q", i, v.Unit())
+		}
+		if s := v.FormatUnit(); s != want {
+			t.Errorf("value %d: got %q, want %q", i, s, want)
+		}
+	}
+
+	ts, err = ParseTypeSpec("u")
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := Decode([]byte{0, 7}, []*TypeSpec{ts})[0]
+	if s := v.FormatUnit(); s != "7" {
+		t.Errorf("value without unit: got %q", s)
+	}
+}
@@ -0,0 +1,103 @@
+package regtype
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/knieriem/modbus"
+	"github.com/knieriem/modbus/register"
+)
+
+// A Sample contains the values decoded from one read
+// of a Sampler, or the error that occurred.
+type Sample struct {
+	Time   time.Time
+	Values []Value
+	Err    error
+}
+
+// A Sampler periodically reads a range of registers
+// and delivers the decoded values as Samples.
+type Sampler struct {
+	Read     register.ReadFunc
+	Addr     uint16
+	Specs    []*TypeSpec
+	Interval time.Duration
+
+	// DropIfBusy specifies whether a Sample shall be dropped
+	// if the receiver has not yet consumed the previous one.
+	// By default, the Sampler blocks until the Sample
+	// has been received.
+	DropIfBusy bool
+
+	EncodingOptions []EncodingOption
+	ReqOptions      []modbus.ReqOption
+}
+
+// NewSampler returns a Sampler that reads the registers
+// described by specs, starting at addr, using the read function f,
+// e.g. the ReadInputRegs method of a register.Device.
+func NewSampler(f register.ReadFunc, addr uint16, specs []*TypeSpec, interval time.Duration) *Sampler {
+	return &Sampler{
+		Read:     f,
+		Addr:     addr,
+		Specs:    specs,
+		Interval: interval,
+	}
+}
+
+// Start starts a goroutine that polls the registers until ctx
+// is cancelled. The returned channel will be closed after
+// the goroutine has terminated. An error is returned
+// if the Interval is not positive.
+func (s *Sampler) Start(ctx context.Context) (<-chan Sample, error) {
+	if s.Interval <= 0 {
+		return nil, errors.New("sampler interval must be positive")
+	}
+	c := make(chan Sample)
+	go s.run(ctx, c)
+	return c, nil
+}
+
+func (s *Sampler) run(ctx context.Context, c chan<- Sample) {
+	defer close(c)
+
+	nBytes := 0
+	for _, ts := range s.Specs {
+		nBytes += ts.NReg() * 2
+	}
+	buf := make([]byte, nBytes)
+	opts := append(s.ReqOptions[:len(s.ReqOptions):len(s.ReqOptions)], modbus.WithContext(ctx))
+
+	tick := time.NewTicker(s.Interval)
+	defer tick.Stop()
+	for {
+		var smp Sample
+		smp.Time = time.Now()
+		smp.Err = s.Read(s.Addr, buf, opts...)
+		if ctx.Err() != nil {
+			return
+		}
+		if smp.Err == nil {
+			smp.Values = Decode(buf, s.Specs, s.EncodingOptions...)
+		}
+		if s.DropIfBusy {
+			select {
+			case c <- smp:
+			default:
+			}
+		} else {
+			select {
+			case c <- smp:
+			case <-ctx.Done():
+				return
+			}
+		}
+		select {
+		case <-tick.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package regtype
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/knieriem/modbus"
+)
+
+// counterRead returns a read function filling the buffer
+// with 16-bit values equal to the number of calls.
+func counterRead() func(uint16, interface{}, ...modbus.ReqOption) error {
+	n := uint16(0)
+	return func(_ uint16, data interface{}, _ ...modbus.ReqOption) error {
+		n++
+		b := data.([]byte)
+		for i := 0; i+1 < len(b); i += 2 {
+			modbus.ByteOrder.PutUint16(b[i:], n)
+		}
+		return nil
+	}
+}
+
+func TestSampler(t *testing.T) {
+	specs, _, err := ParseSpecs([]string{"u", "u32"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewSampler(counterRead(), 10, specs, 0)
+	_, err = s.Start(context.Background())
+	if err == nil {
+		t.Fatal("Start accepted a zero interval")
+	}
+
+	s.Interval = time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	c, err := s.Start(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 3; i++ {
+		smp := <-c
+		if smp.Err != nil {
+			t.Fatal(smp.Err)
+		}
+		if len(smp.Values) != 2 {
+			t.Fatalf("got %d values", len(smp.Values))
+		}
+		if v := smp.Values[0].Native(); v != uint16(i) {
+			t.Errorf("sample %d: got %v", i, v)
+		}
+		if v := smp.Values[1].Native(); v != uint32(i)<<16|uint32(i) {
+			t.Errorf("sample %d: got %v", i, v)
+		}
+	}
+	cancel()
+	for range c {
+	}
+}
+
+func TestSamplerDropIfBusy(t *testing.T) {
+	specs, _, err := ParseSpecs([]string{"u"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewSampler(counterRead(), 0, specs, time.Millisecond)
+	s.DropIfBusy = true
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c, err := s.Start(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var prev uint16
+	for i := 0; i < 3; i++ {
+		time.Sleep(10 * time.Millisecond)
+		smp := <-c
+		v := smp.Values[0].Native().(uint16)
+		if v <= prev+1 && i > 0 {
+			t.Errorf("no samples dropped: %d after %d", v, prev)
+		}
+		prev = v
+	}
+}
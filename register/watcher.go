@@ -0,0 +1,63 @@
+package register
+
+import (
+	"bytes"
+
+	"github.com/knieriem/modbus"
+)
+
+// A Change describes a register that changed its value between two polls.
+type Change struct {
+	Addr uint16
+	Old  uint16
+	New  uint16
+}
+
+// A Watcher repeatedly reads a block of registers, and reports
+// the registers that changed since the previous read.
+type Watcher struct {
+	read  ReadFunc
+	start uint16
+	n     int
+	prev  *Block
+}
+
+// NewWatcher returns a Watcher for n registers starting at start,
+// read using f, e.g. the ReadHoldingRegs method of a Device.
+func NewWatcher(f ReadFunc, start uint16, n int) *Watcher {
+	return &Watcher{read: f, start: start, n: n}
+}
+
+// Poll reads the block of registers, and returns the list
+// of changes, ordered by address. On the first call, there is no
+// previous snapshot, so all registers are reported, with Old set to zero.
+// In case of an error, the previous snapshot is kept.
+func (w *Watcher) Poll(opts ...modbus.ReqOption) ([]Change, error) {
+	b, err := ReadBlock(w.read, w.start, w.n, opts...)
+	if err != nil {
+		return nil, err
+	}
+	prev := w.prev
+	w.prev = b
+	if prev != nil && bytes.Equal(prev.Data, b.Data) {
+		return nil, nil
+	}
+	var list []Change
+	for i := 0; i < b.Len(); i++ {
+		c := Change{Addr: w.start + uint16(i), New: b.Uint16At(i)}
+		if prev != nil {
+			c.Old = prev.Uint16At(i)
+			if c.Old == c.New {
+				continue
+			}
+		}
+		list = append(list, c)
+	}
+	return list, nil
+}
+
+// Snapshot returns the block obtained by the latest
+// successful poll, or nil, if there has been none yet.
+func (w *Watcher) Snapshot() *Block {
+	return w.prev
+}
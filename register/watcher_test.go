@@ -0,0 +1,62 @@
+package register_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/knieriem/modbus"
+	"github.com/knieriem/modbus/register"
+)
+
+func TestWatcher(t *testing.T) {
+	regs := []uint16{1, 0, 3}
+	var readErr error
+	read := func(start uint16, data interface{}, _ ...modbus.ReqOption) error {
+		if readErr != nil {
+			return readErr
+		}
+		b := data.([]byte)
+		for i := range regs {
+			modbus.ByteOrder.PutUint16(b[2*i:], regs[i])
+		}
+		return nil
+	}
+	w := register.NewWatcher(read, 10, len(regs))
+	if w.Snapshot() != nil {
+		t.Error("snapshot before the first poll")
+	}
+
+	poll := func(want []register.Change) {
+		t.Helper()
+		list, err := w.Poll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(list, want) {
+			t.Errorf("got %v, want %v", list, want)
+		}
+	}
+
+	// the first poll reports each register, including those being zero
+	poll([]register.Change{{10, 0, 1}, {11, 0, 0}, {12, 0, 3}})
+
+	// no change
+	poll(nil)
+
+	regs[0] = 5
+	regs[2] = 4
+	poll([]register.Change{{10, 1, 5}, {12, 3, 4}})
+
+	// a failed read keeps the previous snapshot
+	readErr = errors.New("read failed")
+	if _, err := w.Poll(); err != readErr {
+		t.Fatalf("got %v, want %v", err, readErr)
+	}
+	if v := w.Snapshot().Uint16At(0); v != 5 {
+		t.Errorf("snapshot after failed read: %d", v)
+	}
+	readErr = nil
+	regs[1] = 7
+	poll([]register.Change{{11, 0, 7}})
+}
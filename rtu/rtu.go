@@ -3,6 +3,7 @@ package rtu
 import (
 	"bytes"
 	"context"
+	"errors"
 	"io"
 	"os"
 	"time"
@@ -30,9 +31,64 @@ type Conn struct {
 	InterframeTimeout time.Duration
 	OnReceiveError    func(*Conn, error)
 
+	// DetectDuplicates enables a heuristic that makes Receive
+	// return ErrDuplicateResp, if a response frame is byte-identical
+	// to the response to the preceding request, and that request
+	// has been identical to the current one. This helps with devices
+	// that occasionally repeat their last response. Note that polling
+	// values that did not change results in identical responses too;
+	// since the remembered response is cleared once a duplicate
+	// has been reported, a retry of the request, as performed when
+	// using the RetryOnInvalidReply option, will succeed then.
+	DetectDuplicates bool
+
+	// CRCErrorTimeoutFactor, if greater than one, enables an adaptive
+	// framing behaviour for noisy links: After a CRC error, which may
+	// result from a frame that has been split due to a gap between
+	// characters, the inter-byte timeout is multiplied by this factor
+	// for the following receptions, e.g. of a retried request.
+	// The original timeout is restored once a frame
+	// has been received successfully.
+	CRCErrorTimeoutFactor int
+	crcBackoff            bool
+
 	expectedLenSpec *modbus.ExpectedRespLenSpec
+
+	// interByteTimeout, if not zero, overrides the default
+	// inter-byte timeout of the stream
+	interByteTimeout time.Duration
+	baud             int
+
+	sendInterceptor func([]byte) []byte
+
+	dup struct {
+		req      []byte
+		prevReq  []byte
+		prevResp []byte
+	}
+}
+
+// ErrDuplicateResp is returned by Receive if DetectDuplicates
+// is enabled and a duplicate response has been detected. It is
+// recognized by modbus.MsgInvalid, so that the request is retried
+// if the RetryOnInvalidReply option is set.
+var ErrDuplicateResp error = duplicateRespError{}
+
+type duplicateRespError struct{}
+
+func (duplicateRespError) Error() string {
+	return "modbus: duplicate response"
 }
 
+func (duplicateRespError) InvalidReply() bool {
+	return true
+}
+
+// minInterByteTimeout is the inter-byte timeout used for
+// baud rates above 19200, as recommended by the Modbus
+// serial line specification; it is also the default.
+const minInterByteTimeout = 1750 * time.Microsecond
+
 func NewNetConn(conn io.ReadWriter) (m *Conn) {
 	m = new(Conn)
 	m.conn = conn
@@ -48,7 +104,7 @@ func NewNetConn(conn io.ReadWriter) (m *Conn) {
 	m.readMgr = serframe.NewStream(conn,
 		serframe.WithInternalBufSize(512),
 		serframe.WithReceptionOptions(
-			serframe.WithInterByteTimeout(1750*time.Microsecond),
+			serframe.WithInterByteTimeout(minInterByteTimeout),
 			serframe.WithFrameInterceptor(func(msg, bnew []byte) (serframe.FrameStatus, error) {
 				m.h.Write(bnew)
 				if m.h.Sum16() != 0 {
@@ -83,6 +139,12 @@ func (m *Conn) Name() string {
 	return "rtu"
 }
 
+// MaxPDULen returns the maximum PDU length of a serial line ADU,
+// which is limited to 256 bytes, including the address and the CRC.
+func (m *Conn) MaxPDULen() int {
+	return 256 - 1 - 2
+}
+
 func (m *Conn) Device() interface{} {
 	return m.conn
 }
@@ -103,6 +165,12 @@ func (m *Conn) Send() (adu modbus.ADU, err error) {
 	adu.PDUStart = 1
 	adu.PDUEnd = -2
 	adu.Bytes = b.Bytes()
+	if f := m.sendInterceptor; f != nil {
+		adu.Bytes = f(append([]byte(nil), adu.Bytes...))
+	}
+	if m.DetectDuplicates {
+		m.dup.req = append(m.dup.req[:0], adu.Bytes...)
+	}
 
 	var opts []serframe.ReceptionOption
 	if m.LocalEcho || localEchoSetByEnv {
@@ -113,7 +181,7 @@ func (m *Conn) Send() (adu modbus.ADU, err error) {
 		return adu, err
 	}
 
-	_, err = b.WriteTo(m.conn)
+	_, err = m.conn.Write(adu.Bytes)
 	if err != nil {
 		m.readMgr.CancelReception()
 	}
@@ -123,6 +191,15 @@ func (m *Conn) Send() (adu modbus.ADU, err error) {
 	return adu, err
 }
 
+// SetSendInterceptor installs a function that may modify
+// a copy of each ADU before it is sent; see modbus.Network.SendInterceptor.
+// The function is applied after the CRC has been appended,
+// so that a modified frame will fail the CRC check of the receiver.
+// If LocalEcho is enabled, the modified frame is expected as echo.
+func (m *Conn) SetSendInterceptor(f func([]byte) []byte) {
+	m.sendInterceptor = f
+}
+
 func (m *Conn) EnableReceive() error {
 	return m.readMgr.StartReception(m.buf.r)
 }
@@ -137,13 +214,18 @@ func (m *Conn) Receive(ctx context.Context, tMax time.Duration, ls *modbus.Expec
 	}
 	m.h.Reset()
 	m.expectedLenSpec = ls
-	adu.Bytes, err = m.readMgr.ReadFrame(ctx,
+	opts := []serframe.ReceptionOption{
 		serframe.WithInitialTimeout(tMax),
+		serframe.WithInterByteTimeout(m.curInterByteTimeout()),
 		serframe.WithExtInterByteTimeout(m.InterframeTimeout),
-	)
+	}
+	adu.Bytes, err = m.readMgr.ReadFrame(ctx, opts...)
 	adu.PDUStart = 1
 	adu.PDUEnd = -2
 	if err != nil {
+		if err == serframe.ErrOverflow {
+			m.discardPending(ctx)
+		}
 		err = ConvertSerframeError(err)
 		return
 	}
@@ -158,11 +240,179 @@ func (m *Conn) Receive(ctx context.Context, tMax time.Duration, ls *modbus.Expec
 	}
 	if m.h.Sum16() != 0 {
 		err = modbus.ErrCRC
+		m.crcBackoff = m.CRCErrorTimeoutFactor > 1
 		return
 	}
+	m.crcBackoff = false
+	if m.DetectDuplicates {
+		err = m.checkDuplicate(adu.Bytes)
+	}
 	return
 }
 
+// curInterByteTimeout returns the inter-byte timeout for the next
+// reception, taking into account an adjustment after a CRC error.
+func (m *Conn) curInterByteTimeout() time.Duration {
+	ibt := m.interByteTimeout
+	if ibt == 0 {
+		ibt = minInterByteTimeout
+	}
+	if m.crcBackoff {
+		ibt *= time.Duration(m.CRCErrorTimeoutFactor)
+	}
+	return ibt
+}
+
+func (m *Conn) checkDuplicate(resp []byte) error {
+	d := &m.dup
+	if bytes.Equal(d.req, d.prevReq) && bytes.Equal(resp, d.prevResp) {
+		d.prevResp = d.prevResp[:0]
+		return ErrDuplicateResp
+	}
+	d.prevReq = append(d.prevReq[:0], d.req...)
+	d.prevResp = append(d.prevResp[:0], resp...)
+	return nil
+}
+
+// SetBaudrate changes the baud rate of the underlying serial port,
+// and adjusts the inter-byte timeout used for framing to 3.5 character
+// times; above 19200 baud a fixed value of 1.75ms is used,
+// as recommended by the Modbus serial line specification.
+func (m *Conn) SetBaudrate(baud int) error {
+	port, ok := m.conn.(serport.Port)
+	if !ok {
+		return errNotAPort
+	}
+	err := port.SetBaudrate(baud)
+	if err != nil {
+		return err
+	}
+	m.baud = baud
+	m.interByteTimeout = minInterByteTimeout
+	if baud > 0 && baud <= 19200 {
+		m.interByteTimeout = time.Duration(3.5 * 11 * float64(time.Second) / float64(baud))
+	}
+	return nil
+}
+
+// Baudrate returns the baud rate set using SetBaudrate,
+// or zero, if it has not been called yet.
+func (m *Conn) Baudrate() int {
+	return m.baud
+}
+
+var errNotAPort = errors.New("rtu: connection is not a serial port")
+
+// ProbeBaud tries the candidate baud rates in order, calling probe
+// with d, a Device on a Network using the Conn, for each rate;
+// since d is provided by the caller, the options of its Network,
+// like the response timeout, apply to the probe requests.
+// ProbeBaud returns the first baud rate at which probe succeeded,
+// e.g. because a device responded with a valid frame.
+// For each candidate, the inter-byte timeout is adjusted by SetBaudrate,
+// and the InterframeTimeout is extended, if necessary, to cover
+// at least 16 character times.
+// If probe fails for all candidates, the original baud rate and timeouts
+// are restored, and the last error is returned. Since the original
+// baud rate cannot be obtained from the serial port, it must have been
+// set using SetBaudrate before.
+func (m *Conn) ProbeBaud(candidates []int, d modbus.Device, probe func(modbus.Device) error) (int, error) {
+	if _, ok := m.conn.(serport.Port); !ok {
+		return 0, errNotAPort
+	}
+	if m.baud == 0 {
+		return 0, errors.New("rtu: original baud rate unknown")
+	}
+	saved := struct {
+		baud             int
+		interByteTimeout time.Duration
+		interframe       time.Duration
+	}{m.baud, m.interByteTimeout, m.InterframeTimeout}
+
+	err := errors.New("rtu: no baud rate candidates")
+	for _, baud := range candidates {
+		err = m.SetBaudrate(baud)
+		if err != nil {
+			break
+		}
+		m.InterframeTimeout = saved.interframe
+		if t := charTimes(16, baud); t > m.InterframeTimeout {
+			m.InterframeTimeout = t
+		}
+		err = probe(d)
+		if err == nil {
+			return baud, nil
+		}
+	}
+	m.SetBaudrate(saved.baud)
+	m.interByteTimeout = saved.interByteTimeout
+	m.InterframeTimeout = saved.interframe
+	return 0, err
+}
+
+// charTimes returns the time needed to transmit n characters
+// of 11 bits at the specified baud rate.
+func charTimes(n int, baud int) time.Duration {
+	if baud <= 0 {
+		return 0
+	}
+	return time.Duration(n) * 11 * time.Second / time.Duration(baud)
+}
+
+// discardPending reads and discards bytes still arriving
+// after a receive buffer overflow, until the line is quiet
+// for the duration of the interframe timeout, so that
+// the next request starts with an empty buffer.
+func (m *Conn) discardPending(ctx context.Context) {
+	for i := 0; i < 16; i++ {
+		if m.readMgr.StartReception(m.buf.r) != nil {
+			return
+		}
+		m.h.Reset()
+		_, err := m.readMgr.ReadFrame(ctx,
+			serframe.WithInitialTimeout(m.InterframeTimeout),
+			serframe.WithExtInterByteTimeout(0),
+		)
+		if err != nil && err != serframe.ErrOverflow {
+			return
+		}
+	}
+}
+
+// DiscardFrames receives and discards frames for the duration d,
+// starting with the reception enabled by the preceding Send.
+// It is meant to be called after a broadcast request, to which
+// devices must not respond. For each frame received,
+// f, if not nil, is called. If LocalEcho is enabled, the echo
+// of the request is verified and skipped by the reception
+// started by Send, so that only frames sent by devices are reported.
+func (m *Conn) DiscardFrames(ctx context.Context, d time.Duration, f func(frame []byte)) {
+	// The length specification of a previous request
+	// must not influence the framing of discarded frames.
+	m.expectedLenSpec = nil
+	deadline := time.Now().Add(d)
+	for i := 0; ; i++ {
+		remain := time.Until(deadline)
+		if remain <= 0 {
+			return
+		}
+		if i > 0 && m.readMgr.StartReception(m.buf.r) != nil {
+			return
+		}
+		m.h.Reset()
+		buf, err := m.readMgr.ReadFrame(ctx,
+			serframe.WithInitialTimeout(remain),
+			serframe.WithExtInterByteTimeout(0),
+		)
+		if len(buf) != 0 && f != nil {
+			f(buf)
+		}
+		if err != nil && err != serframe.ErrOverflow {
+			return
+		}
+	}
+}
+
 // In case the inter-char/inter-frame timeout is too short,
 // a message might get truncated – the remaining bytes
 // will be discarded, even if they could have been received,
@@ -0,0 +1,434 @@
+package rtu
+
+import (
+	"bytes"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/knieriem/modbus"
+	"github.com/knieriem/modbus/internal/mocknet"
+	"github.com/knieriem/modbus/register"
+	"github.com/knieriem/serport"
+)
+
+// frame appends the CRC to b.
+func frame(b ...byte) []byte {
+	h := NewHash()
+	h.Write(b)
+	return append(b, h.Sum(nil)...)
+}
+
+// A fakeLine simulates a serial line, with devices responding
+// to the frames written, as determined by respond. Of the
+// serport.Port methods, only those defined below may be used.
+type fakeLine struct {
+	serport.Port
+
+	pr *io.PipeReader
+	pw *io.PipeWriter
+
+	mu      sync.Mutex
+	respond func(l *fakeLine, req []byte) []byte
+	baud    int
+	echo    bool
+	written [][]byte
+}
+
+func newFakeLine(respond func(l *fakeLine, req []byte) []byte) *fakeLine {
+	l := &fakeLine{respond: respond, baud: 19200}
+	l.pr, l.pw = io.Pipe()
+	return l
+}
+
+func (l *fakeLine) Write(p []byte) (int, error) {
+	req := append([]byte(nil), p...)
+	l.mu.Lock()
+	l.written = append(l.written, req)
+	resp := l.respond(l, req)
+	if l.echo {
+		resp = append(req, resp...)
+	}
+	l.mu.Unlock()
+	if len(resp) != 0 {
+		go l.pw.Write(resp)
+	}
+	return len(p), nil
+}
+
+func (l *fakeLine) Read(p []byte) (int, error) {
+	return l.pr.Read(p)
+}
+
+func (l *fakeLine) Close() error {
+	return l.pw.Close()
+}
+
+func (l *fakeLine) Drain() error {
+	return nil
+}
+
+func (l *fakeLine) SetBaudrate(baud int) error {
+	l.mu.Lock()
+	l.baud = baud
+	l.mu.Unlock()
+	return nil
+}
+
+// respondRegs answers read holding registers requests
+// with registers containing their address.
+func respondRegs(_ *fakeLine, req []byte) []byte {
+	if len(req) != 8 || req[1] != 3 {
+		return nil
+	}
+	start := modbus.ByteOrder.Uint16(req[2:])
+	n := int(modbus.ByteOrder.Uint16(req[4:]))
+	resp := []byte{req[0], 3, byte(2 * n)}
+	for i := 0; i < n; i++ {
+		v := start + uint16(i)
+		resp = append(resp, byte(v>>8), byte(v))
+	}
+	return frame(resp...)
+}
+
+func newTestConn(t *testing.T, l *fakeLine) *Conn {
+	t.Helper()
+	m := NewNetConn(l)
+	m.InterframeTimeout = 5 * time.Millisecond
+	t.Cleanup(func() { l.Close() })
+	return m
+}
+
+func TestDuplicateRetried(t *testing.T) {
+	m := newTestConn(t, newFakeLine(respondRegs))
+	m.DetectDuplicates = true
+	netw := modbus.NewNetwork(m)
+	netw.ResponseTimeout = 200 * time.Millisecond
+	d := register.NewDevice(mocknet.Device(netw, 1))
+
+	var v uint16
+	err := d.ReadHoldingRegs(5, &v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = d.ReadHoldingRegs(5, &v)
+	if err != ErrDuplicateResp {
+		t.Fatalf("got %v, want ErrDuplicateResp", err)
+	}
+	if !modbus.MsgInvalid(err) {
+		t.Error("ErrDuplicateResp not recognized by MsgInvalid")
+	}
+
+	// steady polling of an unchanged value succeeds when retrying
+	for i := 0; i < 4; i++ {
+		err = d.ReadHoldingRegs(5, &v, modbus.RetryOnInvalidReply(1, 0))
+		if err != nil {
+			t.Fatalf("poll %d: %v", i, err)
+		}
+		if v != 5 {
+			t.Errorf("poll %d: got %d", i, v)
+		}
+	}
+}
+
+func TestDiscardFramesSkipsEcho(t *testing.T) {
+	// a misbehaving device responding to broadcasts
+	bcastResp := frame(5, 6, 0, 1, 0, 2)
+	l := newFakeLine(func(l *fakeLine, req []byte) []byte {
+		if req[0] == 0 {
+			return bcastResp
+		}
+		return respondRegs(l, req)
+	})
+	l.echo = true
+	m := newTestConn(t, l)
+	m.LocalEcho = true
+	netw := modbus.NewNetwork(m)
+	netw.ResponseTimeout = 200 * time.Millisecond
+	netw.TurnaroundDelay = 50 * time.Millisecond
+	netw.QuietBroadcastWindow = true
+	var discarded [][]byte
+	netw.OnBroadcastResponse = func(frame []byte) {
+		discarded = append(discarded, append([]byte(nil), frame...))
+	}
+	d := register.NewDevice(mocknet.Device(netw, 1))
+	bcast := register.NewDevice(mocknet.Device(netw, 0))
+
+	var v uint16
+	err := d.ReadHoldingRegs(7, &v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = bcast.WriteReg(1, uint16(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(discarded) != 1 || !bytes.Equal(discarded[0], bcastResp) {
+		t.Fatalf("discarded frames: % x, want only % x", discarded, bcastResp)
+	}
+
+	err = d.ReadHoldingRegs(8, &v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != 8 {
+		t.Errorf("got %d, want 8", v)
+	}
+}
+
+func TestProbeBaud(t *testing.T) {
+	l := newFakeLine(func(l *fakeLine, req []byte) []byte {
+		if l.baud != 1200 {
+			return nil
+		}
+		return respondRegs(l, req)
+	})
+	m := newTestConn(t, l)
+	netw := modbus.NewNetwork(m)
+	netw.ResponseTimeout = 300 * time.Millisecond
+	d := mocknet.Device(netw, 1)
+	probe := func(d modbus.Device) error {
+		var v uint16
+		return register.NewDevice(d).ReadHoldingRegs(0, &v)
+	}
+
+	_, err := m.ProbeBaud([]int{1200}, d, probe)
+	if err == nil {
+		t.Fatal("ProbeBaud succeeded without a known original baud rate")
+	}
+
+	err = m.SetBaudrate(38400)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ibt, ift := m.interByteTimeout, m.InterframeTimeout
+
+	_, err = m.ProbeBaud([]int{19200, 4800}, d, probe)
+	if err != modbus.ErrTimeout {
+		t.Fatalf("got %v, want ErrTimeout", err)
+	}
+	if l.baud != 38400 || m.Baudrate() != 38400 {
+		t.Errorf("baud rate not restored: %d", l.baud)
+	}
+	if m.interByteTimeout != ibt || m.InterframeTimeout != ift {
+		t.Errorf("timeouts not restored: %v, %v", m.interByteTimeout, m.InterframeTimeout)
+	}
+
+	baud, err := m.ProbeBaud([]int{19200, 1200, 9600}, d, probe)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if baud != 1200 || l.baud != 1200 {
+		t.Errorf("got baud rate %d, line at %d, want 1200", baud, l.baud)
+	}
+	if want := 32083 * time.Microsecond; m.interByteTimeout/time.Microsecond != want/time.Microsecond {
+		t.Errorf("inter-byte timeout is %v, want %v", m.interByteTimeout, want)
+	}
+	if want := 146666 * time.Microsecond; m.InterframeTimeout/time.Microsecond != want/time.Microsecond {
+		t.Errorf("inter-frame timeout is %v, want %v", m.InterframeTimeout, want)
+	}
+}
+
+func TestSetBaudrateInterByteTimeout(t *testing.T) {
+	m := newTestConn(t, newFakeLine(respondRegs))
+	if ibt := m.curInterByteTimeout(); ibt != minInterByteTimeout {
+		t.Errorf("default: got %v, want %v", ibt, minInterByteTimeout)
+	}
+	for _, tc := range []struct {
+		baud int
+		want time.Duration
+	}{
+		{1200, 32083333},
+		{9600, 4010416},
+		{19200, 2005208},
+		{38400, minInterByteTimeout},
+		{115200, minInterByteTimeout},
+	} {
+		err := m.SetBaudrate(tc.baud)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ibt := m.curInterByteTimeout(); ibt != tc.want {
+			t.Errorf("%d baud: got %v, want %v", tc.baud, ibt, tc.want)
+		}
+	}
+}
+
+func TestCRCErrorTimeoutFactor(t *testing.T) {
+	n := 0
+	l := newFakeLine(func(l *fakeLine, req []byte) []byte {
+		resp := respondRegs(l, req)
+		n++
+		if n%2 == 1 {
+			resp[len(resp)-1] ^= 0x01
+		}
+		return resp
+	})
+	m := newTestConn(t, l)
+	m.CRCErrorTimeoutFactor = 4
+	err := m.SetBaudrate(9600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := m.curInterByteTimeout()
+	netw := modbus.NewNetwork(m)
+	netw.ResponseTimeout = 200 * time.Millisecond
+	d := register.NewDevice(mocknet.Device(netw, 1))
+
+	var v uint16
+	err = d.ReadHoldingRegs(3, &v)
+	if err != modbus.ErrCRC {
+		t.Fatalf("got %v, want ErrCRC", err)
+	}
+	if ibt := m.curInterByteTimeout(); ibt != 4*base {
+		t.Errorf("after CRC error: got %v, want %v", ibt, 4*base)
+	}
+	err = d.ReadHoldingRegs(3, &v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ibt := m.curInterByteTimeout(); ibt != base {
+		t.Errorf("after success: got %v, want %v", ibt, base)
+	}
+
+	// with retries, the CRC error is healed within the request
+	err = d.ReadHoldingRegs(3, &v, modbus.RetryOnInvalidReply(1, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != 3 {
+		t.Errorf("got %d, want 3", v)
+	}
+}
+
+func TestSendInterceptor(t *testing.T) {
+	loopback := false
+	l := newFakeLine(func(l *fakeLine, req []byte) []byte {
+		if loopback {
+			return req
+		}
+		h := NewHash()
+		h.Write(req)
+		if h.Sum16() != 0 {
+			// a device discards frames with a CRC error
+			return nil
+		}
+		return respondRegs(l, req)
+	})
+	m := newTestConn(t, l)
+	netw := modbus.NewNetwork(m)
+	netw.ResponseTimeout = 50 * time.Millisecond
+	d := register.NewDevice(mocknet.Device(netw, 1))
+	flip := func(adu []byte) []byte {
+		adu[2] ^= 0x10
+		return adu
+	}
+
+	// a write single register response is an echo of the request
+	loopback = true
+	err := d.WriteReg(10, uint16(5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the corrupted frame, when looped back, fails the CRC check,
+	// and is recognized as an echo of the request
+	var recvErr error
+	m.OnReceiveError = func(_ *Conn, err error) { recvErr = err }
+	netw.SendInterceptor = flip
+	err = d.WriteReg(10, uint16(5))
+	if recvErr != modbus.ErrCRC {
+		t.Errorf("looped back: Receive returned %v, want ErrCRC", recvErr)
+	}
+	if err != modbus.ErrUnexpectedEcho {
+		t.Errorf("looped back: got %v, want ErrUnexpectedEcho", err)
+	}
+	m.OnReceiveError = nil
+
+	loopback = false
+	var v uint16
+	err = d.ReadHoldingRegs(3, &v)
+	if err != modbus.ErrTimeout {
+		t.Errorf("device: got %v, want ErrTimeout", err)
+	}
+	netw.SendInterceptor = nil
+	err = d.ReadHoldingRegs(3, &v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != 3 {
+		t.Errorf("got %d, want 3", v)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if w := l.written[1]; !bytes.Equal(w, flip(frame(1, 6, 0, 10, 0, 5))) {
+		t.Errorf("sent % x", w)
+	}
+}
+
+type rawReq []byte
+
+func (r rawReq) Encode(w io.Writer) error {
+	_, err := w.Write(r)
+	return err
+}
+
+func TestMaxPDULen(t *testing.T) {
+	l := newFakeLine(func(*fakeLine, []byte) []byte { return nil })
+	netw := modbus.NewNetwork(newTestConn(t, l))
+	netw.TurnaroundDelay = 0
+	if n := netw.MaxRequestLen(); n != 253 {
+		t.Errorf("MaxRequestLen: got %d, want 253", n)
+	}
+	if n := netw.MaxResponseLen(); n != 253 {
+		t.Errorf("MaxResponseLen: got %d, want 253", n)
+	}
+
+	// A broadcast does not wait for a response.
+	err := netw.Request(0, 0x41, make(rawReq, 252), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(l.written) != 1 || len(l.written[0]) != 256 {
+		t.Fatalf("frame not sent completely")
+	}
+	err = netw.Request(0, 0x41, make(rawReq, 253), nil)
+	if err != modbus.ErrMaxReqLenExceeded {
+		t.Errorf("got %v, want ErrMaxReqLenExceeded", err)
+	}
+}
+
+func TestOverflowDiscarded(t *testing.T) {
+	n := 0
+	l := newFakeLine(func(l *fakeLine, req []byte) []byte {
+		n++
+		if n == 1 {
+			// a garbled reply exceeding the receive buffer
+			return bytes.Repeat([]byte{0x55}, 1500)
+		}
+		return respondRegs(l, req)
+	})
+	m := newTestConn(t, l)
+	var rxErrs []error
+	m.OnReceiveError = func(_ *Conn, err error) {
+		rxErrs = append(rxErrs, err)
+	}
+	netw := modbus.NewNetwork(m)
+	netw.ResponseTimeout = 200 * time.Millisecond
+	d := register.NewDevice(mocknet.Device(netw, 1))
+
+	var v uint16
+	err := d.ReadHoldingRegs(9, &v, modbus.RetryOnInvalidReply(1, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != 9 {
+		t.Errorf("got %d, want 9", v)
+	}
+	if len(rxErrs) != 1 || rxErrs[0] != modbus.ErrMaxRespLenExceeded {
+		t.Errorf("receive errors: %v, want only ErrMaxRespLenExceeded", rxErrs)
+	}
+	if len(l.written) != 2 {
+		t.Errorf("%d requests sent, want 2", len(l.written))
+	}
+}
@@ -0,0 +1,167 @@
+// Package server implements the server side of the Modbus
+// register functions on top of a Store. A Handler may be used
+// as the Bus of a modtcp.Server.
+package server
+
+import (
+	"sync"
+
+	"github.com/knieriem/modbus"
+)
+
+// A Store provides access to the registers of a server.
+// In case a register range is not available, a method should
+// return modbus.XIllegalDataAddr; in general, errors of type
+// modbus.Exception are forwarded to the client. The Handler
+// makes sure that the range of registers accessed does not
+// exceed the address space, i.e. addr+n <= 0x10000.
+type Store interface {
+	ReadHolding(addr, n uint16) ([]uint16, error)
+	ReadInput(addr, n uint16) ([]uint16, error)
+	WriteHolding(addr uint16, values []uint16) error
+}
+
+// A Handler implements modbus.Bus, processing requests
+// using the Store. The device address of a request is ignored.
+type Handler struct {
+	Store Store
+}
+
+func NewHandler(s Store) *Handler {
+	return &Handler{Store: s}
+}
+
+const maxReadRegs = 125
+const maxWriteRegs = 123
+
+func (h *Handler) Request(_, fn uint8, req modbus.Request, resp modbus.Response, _ ...modbus.ReqOption) error {
+	data, err := modbus.EncodeData(req)
+	if err != nil {
+		return err
+	}
+	bo := modbus.ByteOrder
+	var out []byte
+	switch modbus.FuncCode(fn) {
+	case modbus.FnReadHolding, modbus.FnReadInput:
+		if len(data) != 4 {
+			return modbus.XIllegalDataVal
+		}
+		addr, n := bo.Uint16(data), bo.Uint16(data[2:])
+		if n == 0 || n > maxReadRegs {
+			return modbus.XIllegalDataVal
+		}
+		if !validRange(addr, n) {
+			return modbus.XIllegalDataAddr
+		}
+		read := h.Store.ReadHolding
+		if modbus.FuncCode(fn) == modbus.FnReadInput {
+			read = h.Store.ReadInput
+		}
+		values, err := read(addr, n)
+		if err != nil {
+			return err
+		}
+		if len(values) != int(n) {
+			return modbus.XDeviceFailure
+		}
+		out = make([]byte, 1+2*n)
+		out[0] = byte(2 * n)
+		for i, v := range values {
+			bo.PutUint16(out[1+2*i:], v)
+		}
+	case modbus.FnWriteSingleReg:
+		if len(data) != 4 {
+			return modbus.XIllegalDataVal
+		}
+		err = h.Store.WriteHolding(bo.Uint16(data), []uint16{bo.Uint16(data[2:])})
+		if err != nil {
+			return err
+		}
+		out = data
+	case modbus.FnWriteMultiRegs:
+		if len(data) < 5 {
+			return modbus.XIllegalDataVal
+		}
+		n := bo.Uint16(data[2:])
+		if n == 0 || n > maxWriteRegs || int(data[4]) != 2*int(n) || len(data) != 5+2*int(n) {
+			return modbus.XIllegalDataVal
+		}
+		addr := bo.Uint16(data)
+		if !validRange(addr, n) {
+			return modbus.XIllegalDataAddr
+		}
+		values := make([]uint16, n)
+		for i := range values {
+			values[i] = bo.Uint16(data[5+2*i:])
+		}
+		err = h.Store.WriteHolding(addr, values)
+		if err != nil {
+			return err
+		}
+		out = data[:4]
+	default:
+		return modbus.XIllegalFunc
+	}
+	if resp == nil {
+		return nil
+	}
+	return resp.Decode(out)
+}
+
+// validRange reports whether n registers starting at addr
+// fit into the address space.
+func validRange(addr, n uint16) bool {
+	return int(addr)+int(n) <= 0x10000
+}
+
+// A MapStore is a Store keeping register values in memory.
+// Only registers present in the maps are accessible.
+// A MapStore may be used by multiple goroutines.
+type MapStore struct {
+	mu      sync.Mutex
+	Holding map[uint16]uint16
+	Input   map[uint16]uint16
+}
+
+func NewMapStore() *MapStore {
+	s := new(MapStore)
+	s.Holding = make(map[uint16]uint16)
+	s.Input = make(map[uint16]uint16)
+	return s
+}
+
+func (s *MapStore) ReadHolding(addr, n uint16) ([]uint16, error) {
+	return s.read(s.Holding, addr, n)
+}
+
+func (s *MapStore) ReadInput(addr, n uint16) ([]uint16, error) {
+	return s.read(s.Input, addr, n)
+}
+
+func (s *MapStore) read(m map[uint16]uint16, addr, n uint16) ([]uint16, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	values := make([]uint16, n)
+	for i := range values {
+		v, ok := m[addr+uint16(i)]
+		if !ok {
+			return nil, modbus.XIllegalDataAddr
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
+func (s *MapStore) WriteHolding(addr uint16, values []uint16) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range values {
+		if _, ok := s.Holding[addr+uint16(i)]; !ok {
+			return modbus.XIllegalDataAddr
+		}
+	}
+	for i, v := range values {
+		s.Holding[addr+uint16(i)] = v
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package server_test
+
+import (
+	"io"
+	"testing"
+
+	"github.com/knieriem/modbus"
+	"github.com/knieriem/modbus/internal/mocknet"
+	"github.com/knieriem/modbus/register"
+	"github.com/knieriem/modbus/server"
+)
+
+// A funcStore calls a function for each register accessed.
+type funcStore struct {
+	read    func(addr uint16) (uint16, error)
+	written map[uint16]uint16
+	calls   int
+}
+
+func (s *funcStore) ReadHolding(addr, n uint16) ([]uint16, error) {
+	s.calls++
+	values := make([]uint16, n)
+	for i := range values {
+		v, err := s.read(addr + uint16(i))
+		if err != nil {
+			return nil, err
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
+func (s *funcStore) ReadInput(addr, n uint16) ([]uint16, error) {
+	return s.ReadHolding(addr, n)
+}
+
+func (s *funcStore) WriteHolding(addr uint16, values []uint16) error {
+	s.calls++
+	for i, v := range values {
+		if _, err := s.read(addr + uint16(i)); err != nil {
+			return err
+		}
+		s.written[addr+uint16(i)] = v
+	}
+	return nil
+}
+
+func newFuncStore() *funcStore {
+	return &funcStore{
+		read: func(addr uint16) (uint16, error) {
+			if addr >= 100 {
+				return 0, modbus.XIllegalDataAddr
+			}
+			return 2 * addr, nil
+		},
+		written: make(map[uint16]uint16),
+	}
+}
+
+type rawData []byte
+
+func (d rawData) Encode(w io.Writer) error {
+	_, err := w.Write(d)
+	return err
+}
+
+func TestHandlerStoreExceptions(t *testing.T) {
+	s := newFuncStore()
+	d := register.NewDevice(mocknet.Device(server.NewHandler(s), 1))
+
+	v := make([]uint16, 3)
+	err := d.ReadHoldingRegs(97, v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v[0] != 194 || v[2] != 198 {
+		t.Errorf("got %v", v)
+	}
+	err = d.ReadHoldingRegs(98, v)
+	if err != modbus.XIllegalDataAddr {
+		t.Errorf("read: got %v, want XIllegalDataAddr", err)
+	}
+	err = d.WriteRegs(99, []uint16{1, 2})
+	if err != modbus.XIllegalDataAddr {
+		t.Errorf("write: got %v, want XIllegalDataAddr", err)
+	}
+	err = d.WriteRegs(10, []uint16{1, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.written[10] != 1 || s.written[11] != 2 {
+		t.Errorf("written: %v", s.written)
+	}
+}
+
+func TestHandlerAddrRange(t *testing.T) {
+	s := newFuncStore()
+	s.read = func(addr uint16) (uint16, error) { return addr, nil }
+	h := server.NewHandler(s)
+	for _, tc := range []struct {
+		name string
+		fn   modbus.FuncCode
+		req  rawData
+		want error
+	}{
+		{"read last", modbus.FnReadHolding, rawData{0xFF, 0xFF, 0, 1}, nil},
+		{"read wrapping", modbus.FnReadHolding, rawData{0xFF, 0xFF, 0, 2}, modbus.XIllegalDataAddr},
+		{"read input wrapping", modbus.FnReadInput, rawData{0xFF, 0x90, 0, 0x7D}, modbus.XIllegalDataAddr},
+		{"write last", modbus.FnWriteMultiRegs, rawData{0xFF, 0xFE, 0, 2, 4, 0, 1, 0, 2}, nil},
+		{"write wrapping", modbus.FnWriteMultiRegs, rawData{0xFF, 0xFF, 0, 2, 4, 0, 1, 0, 2}, modbus.XIllegalDataAddr},
+	} {
+		s.calls = 0
+		err := h.Request(1, uint8(tc.fn), tc.req, nil)
+		if err != tc.want {
+			t.Errorf("%s: got %v, want %v", tc.name, err, tc.want)
+		}
+		if tc.want != nil && s.calls != 0 {
+			t.Errorf("%s: store called for an invalid range", tc.name)
+		}
+	}
+}
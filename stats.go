@@ -1,30 +1,57 @@
 package modbus
 
+import "sync"
+
+// RequestStats counts the results of requests. Update may be
+// called from multiple goroutines; the counters are read
+// using Counts, which returns a consistent copy.
 type RequestStats struct {
-	Num struct {
-		All       int
-		Invalid   int
-		Timeout   int
-		Exception int
-		Other     int
-	}
+	mu  sync.Mutex
+	num RequestCounts
+}
+
+type RequestCounts struct {
+	All       int
+	Invalid   int
+	Timeout   int
+	Exception int
+	Other     int
 }
 
+// Counts returns a copy of the counters.
+func (st *RequestStats) Counts() RequestCounts {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.num
+}
+
+// Percentage returns num relative to the current number of all requests.
+// If Update may be called concurrently, num and the total may
+// not match; in this case, use the Percentage method of the
+// RequestCounts returned by Counts instead.
 func (st *RequestStats) Percentage(num int) float64 {
-	return 100 * float64(num) / float64(st.Num.All)
+	return st.Counts().Percentage(num)
+}
+
+// Percentage returns num, one of the counters of c,
+// relative to the number of all requests.
+func (c RequestCounts) Percentage(num int) float64 {
+	return 100 * float64(num) / float64(c.All)
 }
 
 func (st *RequestStats) Update(err error) {
-	st.Num.All++
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.num.All++
 	if err != nil {
 		if _, ok := err.(Exception); ok {
-			st.Num.Exception++
+			st.num.Exception++
 		} else if MsgInvalid(err) {
-			st.Num.Invalid++
+			st.num.Invalid++
 		} else if err == ErrTimeout {
-			st.Num.Timeout++
+			st.num.Timeout++
 		} else {
-			st.Num.Other++
+			st.num.Other++
 		}
 	}
 }
@@ -0,0 +1,41 @@
+package modbus
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestRequestStatsConcurrent(t *testing.T) {
+	var st RequestStats
+	errs := []error{nil, ErrTimeout, ErrCRC, XIllegalFunc, errors.New("other")}
+
+	const n = 200
+	var wg sync.WaitGroup
+	for _, err := range errs {
+		wg.Add(1)
+		go func(err error) {
+			defer wg.Done()
+			for i := 0; i < n; i++ {
+				st.Update(err)
+				c := st.Counts()
+				if p := c.Percentage(c.Timeout); p < 0 || p > 100 {
+					t.Errorf("invalid percentage: %v", p)
+				}
+				if p := st.Percentage(1); p <= 0 || p > 100 {
+					t.Errorf("invalid percentage: %v", p)
+				}
+			}
+		}(err)
+	}
+	wg.Wait()
+
+	c := st.Counts()
+	want := RequestCounts{All: 5 * n, Invalid: n, Timeout: n, Exception: n, Other: n}
+	if c != want {
+		t.Errorf("got %+v, want %+v", c, want)
+	}
+	if p := c.Percentage(c.Timeout); p != 20 {
+		t.Errorf("timeout percentage: got %v, want 20", p)
+	}
+}
@@ -0,0 +1,48 @@
+package modbus
+
+// A TLV is a single type-length-value item, as used by
+// some vendor specific functions to structure their data.
+type TLV struct {
+	Type  byte
+	Value []byte
+}
+
+// A TLVDecoder is a Response that parses the data part of a
+// response as a sequence of (type byte, length byte, value)
+// triples. The Value fields of the resulting Items refer
+// to copies of the response data.
+type TLVDecoder struct {
+	Items []TLV
+}
+
+var ErrTLVTruncated = Error("truncated TLV item")
+
+func (d *TLVDecoder) Decode(buf []byte) error {
+	items, err := DecodeTLV(buf)
+	if err != nil {
+		return err
+	}
+	d.Items = items
+	return nil
+}
+
+// DecodeTLV splits b into TLV items. It returns ErrTLVTruncated
+// if an item's header or value exceeds the end of b.
+func DecodeTLV(b []byte) ([]TLV, error) {
+	var items []TLV
+
+	for len(b) != 0 {
+		if len(b) < 2 {
+			return nil, ErrTLVTruncated
+		}
+		n := int(b[1])
+		if len(b) < 2+n {
+			return nil, ErrTLVTruncated
+		}
+		v := make([]byte, n)
+		copy(v, b[2:2+n])
+		items = append(items, TLV{Type: b[0], Value: v})
+		b = b[2+n:]
+	}
+	return items, nil
+}
@@ -0,0 +1,55 @@
+package modbus
+
+import (
+	"bytes"
+	"testing"
+)
+
+func FuzzDecodeTLV(f *testing.F) {
+	f.Add([]byte{})
+	f.Add([]byte{1, 0})
+	f.Add([]byte{1, 2, 0xAB, 0xCD, 2, 1, 0xEF})
+	f.Add([]byte{1})
+	f.Add([]byte{1, 5, 0})
+	f.Add([]byte{1, 0xFF})
+	f.Fuzz(func(t *testing.T, b []byte) {
+		items, err := DecodeTLV(b)
+		if err != nil {
+			if err != ErrTLVTruncated {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if items != nil {
+				t.Fatal("items returned together with an error")
+			}
+			return
+		}
+		off := 0
+		for i, it := range items {
+			end := off + 2 + len(it.Value)
+			if end > len(b) {
+				t.Fatalf("item %d exceeds the input", i)
+			}
+			if it.Type != b[off] || int(b[off+1]) != len(it.Value) || !bytes.Equal(it.Value, b[off+2:end]) {
+				t.Fatalf("item %d does not match the input at offset %d", i, off)
+			}
+			off = end
+		}
+		if off != len(b) {
+			t.Fatalf("items cover %d of %d bytes", off, len(b))
+		}
+	})
+}
+
+func TestTLVDecoder(t *testing.T) {
+	var d TLVDecoder
+	err := d.Decode([]byte{1, 2, 0xAB, 0xCD, 2, 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Items) != 2 || d.Items[1].Type != 2 || len(d.Items[1].Value) != 0 {
+		t.Errorf("unexpected items: %v", d.Items)
+	}
+	if err := d.Decode([]byte{1, 3, 0}); err != ErrTLVTruncated {
+		t.Errorf("truncated value: got %v", err)
+	}
+}
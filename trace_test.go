@@ -0,0 +1,140 @@
+package modbus_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/knieriem/modbus"
+	"github.com/knieriem/modbus/debug"
+	"github.com/knieriem/modbus/internal/mocknet"
+	"github.com/knieriem/modbus/register"
+)
+
+func TestTraceAttempts(t *testing.T) {
+	n := 0
+	nc := mocknet.New(func(_ context.Context, req []byte, _ *modbus.ExpectedRespLenSpec) ([]byte, error) {
+		n++
+		switch n {
+		case 1:
+			return nil, nil
+		case 2:
+			return req[:2], modbus.ErrCRC
+		}
+		return req[:2], nil
+	})
+	netw := modbus.NewNetwork(nc)
+	var events []modbus.TraceEvent
+	// the limits apply to the total number of retries
+	err := netw.Request(1, 0x41, rawData{0, 1}, nil,
+		modbus.RetryOnTimeout(2, 0),
+		modbus.RetryOnInvalidReply(2, 0),
+		modbus.WithTraceEventFunc(func(ev *modbus.TraceEvent) {
+			events = append(events, *ev)
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		dir     string
+		attempt int
+		reason  string
+		err     error
+	}{
+		{modbus.MsgDirReq, 1, "", nil},
+		{modbus.MsgDirResp, 1, "", modbus.ErrTimeout},
+		{modbus.MsgDirReq, 2, "timeout", nil},
+		{modbus.MsgDirResp, 2, "timeout", modbus.ErrCRC},
+		{modbus.MsgDirReq, 3, "invalid reply", nil},
+		{modbus.MsgDirResp, 3, "invalid reply", nil},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("got %d events, want %d", len(events), len(want))
+	}
+	for i, w := range want {
+		ev := &events[i]
+		if ev.MsgDir != w.dir || ev.Attempt != w.attempt || ev.RetryReason != w.reason || ev.Err != w.err {
+			t.Errorf("event %d: got %s attempt %d %q %v, want %s attempt %d %q %v",
+				i, ev.MsgDir, ev.Attempt, ev.RetryReason, ev.Err, w.dir, w.attempt, w.reason, w.err)
+		}
+	}
+	if s := debug.FormatEvent(&events[4]); !strings.HasSuffix(s, "(attempt 3, retry after invalid reply)") {
+		t.Errorf("formatted event: %q", s)
+	}
+	if s := debug.FormatEvent(&events[0]); strings.Contains(s, "attempt") {
+		t.Errorf("first attempt labeled: %q", s)
+	}
+}
+
+func TestTraceLabels(t *testing.T) {
+	nc := mocknet.New(func(_ context.Context, req []byte, _ *modbus.ExpectedRespLenSpec) ([]byte, error) {
+		return req[:2], nil
+	})
+	netw := modbus.NewNetwork(nc)
+	var events []modbus.TraceEvent
+	netw.TraceEventf = func(ev *modbus.TraceEvent) {
+		events = append(events, *ev)
+	}
+	err := netw.Request(1, 0x41, rawData{0, 1}, nil,
+		modbus.WithLabel("job", "42"),
+		modbus.WithLabel("reg", "setpoint"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("got %d events, want 2", len(events))
+	}
+	for _, ev := range events {
+		if ev.Labels["job"] != "42" || ev.Labels["reg"] != "setpoint" || len(ev.Labels) != 2 {
+			t.Errorf("%s: got labels %v", ev.MsgDir, ev.Labels)
+		}
+	}
+
+	// labels are not kept for subsequent requests
+	events = nil
+	err = netw.Request(1, 0x41, rawData{0, 1}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l := events[0].Labels; len(l) != 0 {
+		t.Errorf("unlabeled request: got labels %v", l)
+	}
+}
+
+func TestTraceDecoded(t *testing.T) {
+	nc := mocknet.New(func(_ context.Context, req []byte, _ *modbus.ExpectedRespLenSpec) ([]byte, error) {
+		return []byte{req[0], req[1], 6, 0, 1, 0, 2, 0x12, 0x34}, nil
+	})
+	netw := modbus.NewNetwork(nc)
+	var events []modbus.TraceEvent
+	netw.TraceEventf = func(ev *modbus.TraceEvent) {
+		events = append(events, *ev)
+	}
+	d := register.NewDevice(mocknet.Device(netw, 1))
+
+	var regs [3]uint16
+	for _, enabled := range []bool{false, true} {
+		events = nil
+		netw.TraceDecoded = enabled
+		err := d.ReadHoldingRegs(0, regs[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(events) != 2 {
+			t.Fatalf("got %d events, want 2", len(events))
+		}
+		if s := events[0].Decoded; s != "" {
+			t.Errorf("request event: decoded %q", s)
+		}
+		want := ""
+		if enabled {
+			want = "[1 2 4660]"
+		}
+		if s := events[1].Decoded; s != want {
+			t.Errorf("TraceDecoded %v: got %q, want %q", enabled, s, want)
+		}
+	}
+	if s := debug.FormatEvent(&events[1]); !strings.HasSuffix(s, " = [1 2 4660]") {
+		t.Errorf("formatted event: %q", s)
+	}
+}